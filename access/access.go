@@ -0,0 +1,54 @@
+// Package access defines organizer roles and what each of them may do.
+package access
+
+// Role is an organizer role stored in the admins table
+type Role string
+
+const (
+	Owner     Role = "owner"     // всё, включая выдачу и отзыв ролей
+	Admin     Role = "admin"     // управление участниками, сетками и настройками
+	Moderator Role = "moderator" // просмотр участников и сеток
+	Referee   Role = "referee"   // просмотр сеток и выставление результатов
+)
+
+// Permission is a single action guarded by a role check
+type Permission string
+
+const (
+	ViewUsers     Permission = "view_users"     // /users, /find, /user, /stats
+	DeleteUsers   Permission = "delete_users"   // /delete
+	ViewBracket   Permission = "view_bracket"   // /bracket
+	ManageBracket Permission = "manage_bracket" // /bracket_gen, /checkin, /schedule
+	SetResults    Permission = "set_results"    // /result
+	Settings      Permission = "settings"       // /deadline
+	Backup        Permission = "backup"         // /backup
+	ManageRoles   Permission = "manage_roles"   // /grant, /revoke, /admins
+	Broadcast     Permission = "broadcast"      // /broadcast
+)
+
+var rolePermissions = map[Role][]Permission{
+	Owner:     {ViewUsers, DeleteUsers, ViewBracket, ManageBracket, SetResults, Settings, Backup, Broadcast, ManageRoles},
+	Admin:     {ViewUsers, DeleteUsers, ViewBracket, ManageBracket, SetResults, Settings, Backup, Broadcast},
+	Moderator: {ViewUsers, ViewBracket},
+	Referee:   {ViewBracket, SetResults},
+}
+
+// Roles lists all roles from the most to the least powerful
+var Roles = []Role{Owner, Admin, Moderator, Referee}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, bool) {
+	r := Role(s)
+	_, ok := rolePermissions[r]
+	return r, ok
+}
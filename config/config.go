@@ -3,13 +3,30 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // TIMEZONE must load even on images without system zoneinfo
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	TelegramToken string
-	DBDSN         string
+	TelegramToken   string
+	DBDSN           string
+	DisciplinesFile string  // JSON со списком дисциплин; пусто — встроенный список
+	AdminIDs        []int64 // владельцы бота, назначаются при старте
+	AdminChatID     int64   // чат организаторов для уведомлений и бэкапов
+	Port            string  // порт HTTP-сервера (health check и вебхук)
+	WebhookURL      string  // публичный URL вебхука; пусто — long polling
+	WebhookSecret   string  // секрет, который Telegram присылает в заголовке
+	Workers         int     // число параллельных обработчиков обновлений
+	QueueSize       int     // длина очереди обновлений у каждого обработчика
+
+	SessionRemindAfter time.Duration // через сколько без ответа напомнить о незаконченной анкете
+	SessionTTL         time.Duration // через сколько без ответа удалить незаконченную анкету
+
+	Location *time.Location // часовой пояс турнира: в нём вводятся и показываются даты
 }
 
 // Load reads environment variables (supports .env) and builds Postgres DSN
@@ -35,5 +52,144 @@ func Load() (*Config, error) {
 		}
 	}
 
-	return &Config{TelegramToken: token, DBDSN: dsn}, nil
+	// ADMIN_IDS: comma-separated Telegram IDs that are bootstrapped as owners
+	adminIDs, err := parseIDs(os.Getenv("ADMIN_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("ADMIN_IDS: %w", err)
+	}
+
+	// Without either variable nobody could manage the bot and backups would stop silently
+	if len(adminIDs) == 0 && os.Getenv("ADMIN_CHAT_ID") == "" {
+		return nil, fmt.Errorf("ADMIN_IDS or ADMIN_CHAT_ID must be set: without them nobody can manage the bot and backups have nowhere to go")
+	}
+
+	// ADMIN_CHAT_ID defaults to the first owner's private chat
+	var adminChatID int64
+	if v := os.Getenv("ADMIN_CHAT_ID"); v != "" {
+		adminChatID, err = strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ADMIN_CHAT_ID: %w", err)
+		}
+	} else if len(adminIDs) > 0 {
+		adminChatID = adminIDs[0]
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "10000" // Render uses 10000 by default
+	}
+
+	// WEBHOOK_URL switches the bot to webhook mode; the secret is then mandatory
+	// so that nobody else can post fake updates to the endpoint
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	if webhookURL != "" && !validWebhookSecret(webhookSecret) {
+		return nil, fmt.Errorf("WEBHOOK_SECRET must be 1-256 characters of A-Z, a-z, 0-9, _ and - when WEBHOOK_URL is set")
+	}
+
+	workers, err := intEnv("WORKERS", 8)
+	if err != nil {
+		return nil, err
+	}
+	queueSize, err := intEnv("UPDATE_QUEUE_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	// SESSION_REMIND_AFTER and SESSION_TTL accept Go durations such as 6h or 90m
+	remindAfter, err := durationEnv("SESSION_REMIND_AFTER", 6*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	sessionTTL, err := durationEnv("SESSION_TTL", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if sessionTTL <= remindAfter {
+		return nil, fmt.Errorf("SESSION_TTL (%s) must be longer than SESSION_REMIND_AFTER (%s)", sessionTTL, remindAfter)
+	}
+
+	// TIMEZONE is the IANA zone of the tournament, e.g. Europe/Moscow. Organizers
+	// type dates in it; unset means the server's zone, which is UTC on Render
+	location := time.Local
+	if v := strings.TrimSpace(os.Getenv("TIMEZONE")); v != "" {
+		location, err = time.LoadLocation(v)
+		if err != nil {
+			return nil, fmt.Errorf("TIMEZONE: %w", err)
+		}
+	}
+
+	return &Config{
+		TelegramToken:   token,
+		DBDSN:           dsn,
+		DisciplinesFile: os.Getenv("DISCIPLINES_FILE"),
+		AdminIDs:        adminIDs,
+		AdminChatID:     adminChatID,
+		Port:            port,
+		WebhookURL:      webhookURL,
+		WebhookSecret:   webhookSecret,
+		Workers:         workers,
+		QueueSize:       queueSize,
+
+		SessionRemindAfter: remindAfter,
+		SessionTTL:         sessionTTL,
+
+		Location: location,
+	}, nil
+}
+
+// intEnv reads a positive integer variable, falling back to def when it is unset
+func intEnv(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// durationEnv reads a positive duration variable, falling back to def when it is unset
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 6h, got %q", key, v)
+	}
+	return d, nil
+}
+
+// validWebhookSecret checks the secret against Telegram's allowed alphabet
+func validWebhookSecret(s string) bool {
+	if len(s) == 0 || len(s) > 256 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseIDs parses a comma-separated list of Telegram IDs, ignoring blanks
+func parseIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad id %q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
\ No newline at end of file
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AdminRecord is a row of the admins table
+type AdminRecord struct {
+	TelegramID int64
+	Role       string
+	AddedBy    int64
+	CreatedAt  time.Time
+}
+
+// GetAdminRole returns the role of the Telegram user; sql.ErrNoRows if they are not an organizer
+func GetAdminRole(ctx context.Context, db *sql.DB, tgID int64) (string, error) {
+	var role string
+	err := db.QueryRowContext(ctx, `SELECT role FROM admins WHERE tg_id = $1`, tgID).Scan(&role)
+	return role, err
+}
+
+// SetAdminRole grants the role to the user (upsert on tg_id)
+func SetAdminRole(ctx context.Context, db *sql.DB, tgID int64, role string, addedBy int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admins (tg_id, role, added_by) VALUES ($1, $2, $3)
+		ON CONFLICT (tg_id) DO UPDATE SET role = EXCLUDED.role, added_by = EXCLUDED.added_by
+	`, tgID, role, addedBy)
+	return err
+}
+
+// EnsureOwner makes the user an owner; used to bootstrap owners from ADMIN_IDS.
+// /grant and /revoke refuse these users, so a restart undoes no one's decision
+func EnsureOwner(ctx context.Context, db *sql.DB, tgID int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admins (tg_id, role) VALUES ($1, 'owner')
+		ON CONFLICT (tg_id) DO UPDATE SET role = 'owner'
+	`, tgID)
+	return err
+}
+
+// DeleteAdmin revokes all organizer rights of the user
+func DeleteAdmin(ctx context.Context, db *sql.DB, tgID int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM admins WHERE tg_id = $1`, tgID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// CountOwners returns how many owners there are
+func CountOwners(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM admins WHERE role = 'owner'`).Scan(&n)
+	return n, err
+}
+
+// ListAdmins returns all organizers, oldest first
+func ListAdmins(ctx context.Context, db *sql.DB) ([]AdminRecord, error) {
+	rows, err := db.QueryContext(ctx, `SELECT tg_id, role, added_by, created_at FROM admins ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var admins []AdminRecord
+	for rows.Next() {
+		var a AdminRecord
+		if err := rows.Scan(&a.TelegramID, &a.Role, &a.AddedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		admins = append(admins, a)
+	}
+	return admins, rows.Err()
+}
+
+// AdminRepository exposes the admins table to code that takes its
+// dependencies as interfaces
+type AdminRepository struct {
+	db *sql.DB
+}
+
+func NewAdminRepository(db *sql.DB) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+func (r *AdminRepository) Role(ctx context.Context, tgID int64) (string, error) {
+	return GetAdminRole(ctx, r.db, tgID)
+}
+
+func (r *AdminRepository) SetRole(ctx context.Context, tgID int64, role string, addedBy int64) error {
+	return SetAdminRole(ctx, r.db, tgID, role, addedBy)
+}
+
+func (r *AdminRepository) Delete(ctx context.Context, tgID int64) error {
+	return DeleteAdmin(ctx, r.db, tgID)
+}
+
+func (r *AdminRepository) CountOwners(ctx context.Context) (int, error) {
+	return CountOwners(ctx, r.db)
+}
+
+func (r *AdminRepository) List(ctx context.Context) ([]AdminRecord, error) {
+	return ListAdmins(ctx, r.db)
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"tgbot/models"
+)
+
+// ListBroadcastRecipients returns active users matching the broadcast target
+func ListBroadcastRecipients(ctx context.Context, db *sql.DB, target, value string) ([]models.User, error) {
+	switch target {
+	case models.TargetDiscipline:
+		return queryUsers(ctx, db, `SELECT `+userColumns+` FROM users
+			WHERE status = 'active' AND disciplines ? $1
+			ORDER BY id`, value)
+	case models.TargetClass:
+		return queryUsers(ctx, db, `SELECT `+userColumns+` FROM users
+			WHERE status = 'active' AND lower(class) = lower($1)
+			ORDER BY id`, value)
+	default:
+		return queryUsers(ctx, db, `SELECT `+userColumns+` FROM users
+			WHERE status = 'active'
+			ORDER BY id`)
+	}
+}
+
+// CreateBroadcast stores the broadcast and a pending delivery row per recipient
+func CreateBroadcast(ctx context.Context, db *sql.DB, b *models.Broadcast, recipients []int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO broadcasts (author_id, target, target_value, kind, text, file_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, b.AuthorID, b.Target, b.TargetValue, b.Kind, b.Text, b.FileID).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO broadcast_deliveries (broadcast_id, tg_id) VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, tgID := range recipients {
+		if _, err := stmt.ExecContext(ctx, b.ID, tgID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetDeliveryStatus records the outcome of sending the broadcast to one recipient
+func SetDeliveryStatus(ctx context.Context, db *sql.DB, broadcastID, tgID int64, status, errText string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE broadcast_deliveries
+		SET status = $3, error = $4, sent_at = CASE WHEN $3 = 'sent' THEN now() ELSE sent_at END
+		WHERE broadcast_id = $1 AND tg_id = $2
+	`, broadcastID, tgID, status, errText)
+	return err
+}
+
+// FinishBroadcast marks the broadcast as fully processed
+func FinishBroadcast(ctx context.Context, db *sql.DB, broadcastID int64) error {
+	_, err := db.ExecContext(ctx, `UPDATE broadcasts SET finished_at = now() WHERE id = $1`, broadcastID)
+	return err
+}
+
+// BroadcastRepository exposes broadcasts and their deliveries to code that
+// takes its dependencies as interfaces
+type BroadcastRepository struct {
+	db *sql.DB
+}
+
+func NewBroadcastRepository(db *sql.DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+func (r *BroadcastRepository) Recipients(ctx context.Context, target, value string) ([]models.User, error) {
+	return ListBroadcastRecipients(ctx, r.db, target, value)
+}
+
+func (r *BroadcastRepository) Create(ctx context.Context, b *models.Broadcast, recipients []int64) error {
+	return CreateBroadcast(ctx, r.db, b, recipients)
+}
+
+func (r *BroadcastRepository) SetDeliveryStatus(ctx context.Context, broadcastID, tgID int64, status, errText string) error {
+	return SetDeliveryStatus(ctx, r.db, broadcastID, tgID, status, errText)
+}
+
+func (r *BroadcastRepository) Finish(ctx context.Context, broadcastID int64) error {
+	return FinishBroadcast(ctx, r.db, broadcastID)
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tgbot/models"
+)
+
+// OpenCheckin opens (or reopens) the check-in window of the game until closes.
+// Check-ins made in an earlier window are kept
+func OpenCheckin(ctx context.Context, db *sql.DB, game string, closes time.Time, remindEvery time.Duration) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO checkin_windows (discipline, opens_at, closes_at, remind_every, reminded_at)
+		VALUES ($1, now(), $2, $3, now())
+		ON CONFLICT (discipline) DO UPDATE SET
+			opens_at = EXCLUDED.opens_at,
+			closes_at = EXCLUDED.closes_at,
+			remind_every = EXCLUDED.remind_every,
+			reminded_at = EXCLUDED.reminded_at
+	`, game, closes, int(remindEvery.Seconds()))
+	return err
+}
+
+// CloseCheckin ends the open check-in window of the game now; sql.ErrNoRows if it is not open
+func CloseCheckin(ctx context.Context, db *sql.DB, game string) error {
+	res, err := db.ExecContext(ctx, `
+		UPDATE checkin_windows SET closes_at = now()
+		WHERE discipline = $1 AND closes_at > now()
+	`, game)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// ResetCheckin forgets the window and all check-ins of the game
+func ResetCheckin(ctx context.Context, db *sql.DB, game string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checkins WHERE discipline = $1`, game); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checkin_windows WHERE discipline = $1`, game); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCheckinWindow returns the check-in window of the game; sql.ErrNoRows if there was none
+func GetCheckinWindow(ctx context.Context, db *sql.DB, game string) (*models.CheckinWindow, error) {
+	w := &models.CheckinWindow{}
+	row := db.QueryRowContext(ctx, `SELECT `+checkinWindowColumns+` FROM checkin_windows WHERE discipline = $1`, game)
+	if err := scanCheckinWindow(row, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ListCheckinWindows returns the check-in windows of all games
+func ListCheckinWindows(ctx context.Context, db *sql.DB) ([]models.CheckinWindow, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+checkinWindowColumns+` FROM checkin_windows ORDER BY discipline`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []models.CheckinWindow
+	for rows.Next() {
+		var w models.CheckinWindow
+		if err := scanCheckinWindow(rows, &w); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// MarkCheckinReminded records when the last reminder of the game was sent
+func MarkCheckinReminded(ctx context.Context, db *sql.DB, game string, at time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE checkin_windows SET reminded_at = $2 WHERE discipline = $1`, game, at)
+	return err
+}
+
+// CheckIn records that the user will play the game; a repeated check-in keeps the first time
+func CheckIn(ctx context.Context, db *sql.DB, game string, tgID int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO checkins (discipline, tg_id) VALUES ($1, $2)
+		ON CONFLICT (discipline, tg_id) DO NOTHING
+	`, game, tgID)
+	return err
+}
+
+// CheckedIn returns the set of users checked in to the game
+func CheckedIn(ctx context.Context, db *sql.DB, game string) (map[int64]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT tg_id FROM checkins WHERE discipline = $1`, game)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+const checkinWindowColumns = `discipline, opens_at, closes_at, remind_every, reminded_at`
+
+func scanCheckinWindow(row interface{ Scan(...any) error }, w *models.CheckinWindow) error {
+	var remindEvery int
+	if err := row.Scan(&w.Discipline, &w.OpensAt, &w.ClosesAt, &remindEvery, &w.RemindedAt); err != nil {
+		return err
+	}
+	w.RemindEvery = time.Duration(remindEvery) * time.Second
+	return nil
+}
+
+// CheckinRepository exposes check-in windows and check-ins to code that takes
+// its dependencies as interfaces
+type CheckinRepository struct {
+	db *sql.DB
+}
+
+func NewCheckinRepository(db *sql.DB) *CheckinRepository {
+	return &CheckinRepository{db: db}
+}
+
+func (r *CheckinRepository) Open(ctx context.Context, game string, closes time.Time, remindEvery time.Duration) error {
+	return OpenCheckin(ctx, r.db, game, closes, remindEvery)
+}
+
+func (r *CheckinRepository) Close(ctx context.Context, game string) error {
+	return CloseCheckin(ctx, r.db, game)
+}
+
+func (r *CheckinRepository) Reset(ctx context.Context, game string) error {
+	return ResetCheckin(ctx, r.db, game)
+}
+
+func (r *CheckinRepository) Window(ctx context.Context, game string) (*models.CheckinWindow, error) {
+	return GetCheckinWindow(ctx, r.db, game)
+}
+
+func (r *CheckinRepository) Windows(ctx context.Context) ([]models.CheckinWindow, error) {
+	return ListCheckinWindows(ctx, r.db)
+}
+
+func (r *CheckinRepository) MarkReminded(ctx context.Context, game string, at time.Time) error {
+	return MarkCheckinReminded(ctx, r.db, game, at)
+}
+
+func (r *CheckinRepository) CheckIn(ctx context.Context, game string, tgID int64) error {
+	return CheckIn(ctx, r.db, game, tgID)
+}
+
+func (r *CheckinRepository) CheckedIn(ctx context.Context, game string) (map[int64]bool, error) {
+	return CheckedIn(ctx, r.db, game)
+}
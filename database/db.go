@@ -1,18 +1,32 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/lib/pq"
 )
 
 // Open connects to Postgres using DSN and runs migrations
-func Open(dsn string) (*sql.DB, error) {
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := Connect(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Connect connects to Postgres without touching the schema
+func Connect(ctx context.Context, dsn string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err := migrate(db); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tgbot/models"
+)
+
+// JobRepository keeps scheduled jobs in the jobs table (implements
+// scheduler.Store and handlers.Jobs)
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// Put stores a one-shot job under its (non-empty) key, replacing an earlier
+// job with the same key even if it has already run
+func (r *JobRepository) Put(ctx context.Context, job models.Job) error {
+	payload := payloadOrEmpty(job.Payload)
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO jobs (key, kind, payload, run_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			kind = EXCLUDED.kind,
+			payload = EXCLUDED.payload,
+			run_at = EXCLUDED.run_at,
+			every = 0,
+			attempts = 0,
+			last_error = '',
+			done_at = NULL
+	`, job.Key, job.Kind, payload, job.RunAt)
+	return err
+}
+
+// Every makes sure the recurring job with the given key runs every interval.
+// An existing job keeps its next run unless the new interval brings it closer
+func (r *JobRepository) Every(ctx context.Context, key, kind string, interval time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO jobs (key, kind, run_at, every)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			kind = EXCLUDED.kind,
+			every = EXCLUDED.every,
+			run_at = LEAST(jobs.run_at, EXCLUDED.run_at),
+			done_at = NULL
+	`, key, kind, time.Now().Add(interval), int(interval.Seconds()))
+	return err
+}
+
+// Cancel drops the pending job with the given key; a missing job is not an error
+func (r *JobRepository) Cancel(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM jobs WHERE key = $1 AND done_at IS NULL`, key)
+	return err
+}
+
+// Claim takes up to limit jobs that are due at now. Each claimed job is pushed
+// lease into the future, so if the process dies while running it, the job runs
+// again after the lease instead of being lost
+func (r *JobRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Job, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE jobs SET run_at = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE done_at IS NULL AND run_at <= $1
+			ORDER BY run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, COALESCE(key, ''), kind, payload, every, attempts, last_error
+	`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		var j models.Job
+		var every int
+		if err := rows.Scan(&j.ID, &j.Key, &j.Kind, &j.Payload, &every, &j.Attempts, &j.LastError); err != nil {
+			return nil, err
+		}
+		j.RunAt = now
+		j.Every = time.Duration(every) * time.Second
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// Complete records a successful run: the job runs again at next, or is marked
+// done if next is zero
+func (r *JobRepository) Complete(ctx context.Context, id int64, next time.Time) error {
+	return r.finish(ctx, id, "", next)
+}
+
+// Fail records a failed run: the job is retried at retry, or given up if retry is zero
+func (r *JobRepository) Fail(ctx context.Context, id int64, msg string, retry time.Time) error {
+	return r.finish(ctx, id, msg, retry)
+}
+
+func (r *JobRepository) finish(ctx context.Context, id int64, msg string, next time.Time) error {
+	var err error
+	switch {
+	case next.IsZero():
+		_, err = r.db.ExecContext(ctx, `UPDATE jobs SET done_at = now(), last_error = $2 WHERE id = $1`, id, msg)
+	case msg == "":
+		_, err = r.db.ExecContext(ctx, `UPDATE jobs SET run_at = $2, attempts = 0, last_error = '' WHERE id = $1`, id, next)
+	default:
+		_, err = r.db.ExecContext(ctx, `UPDATE jobs SET run_at = $2, last_error = $3 WHERE id = $1`, id, next, msg)
+	}
+	return err
+}
+
+// Purge deletes jobs that finished before the given time
+func (r *JobRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM jobs WHERE done_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func payloadOrEmpty(payload []byte) []byte {
+	if len(payload) == 0 {
+		return []byte(`{}`)
+	}
+	return payload
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tgbot/models"
+)
+
+const matchColumns = `id, discipline, stage, group_name, round, slot, source1, source2,
+	player1, player2, score1, score2, winner, status, reported_by, starts_at`
+
+func scanMatch(row interface{ Scan(...any) error }, m *models.Match) error {
+	var startsAt sql.NullTime
+	err := row.Scan(&m.ID, &m.Discipline, &m.Stage, &m.GroupName, &m.Round, &m.Slot,
+		&m.Source1, &m.Source2, &m.Player1, &m.Player2, &m.Score1, &m.Score2, &m.Winner, &m.Status, &m.ReportedBy, &startsAt)
+	m.StartsAt = startsAt.Time
+	return err
+}
+
+// ReplaceMatches deletes the bracket of the discipline and stores a new one,
+// filling in the IDs of the inserted matches
+func ReplaceMatches(ctx context.Context, db *sql.DB, discipline string, matches []models.Match) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM matches WHERE discipline = $1`, discipline); err != nil {
+		return err
+	}
+	for i := range matches {
+		m := &matches[i]
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO matches (discipline, stage, group_name, round, slot, source1, source2,
+				player1, player2, score1, score2, winner, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id
+		`, m.Discipline, m.Stage, m.GroupName, m.Round, m.Slot, m.Source1, m.Source2,
+			m.Player1, m.Player2, m.Score1, m.Score2, m.Winner, m.Status).Scan(&m.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListMatches returns the bracket of the discipline: groups first, then playoff rounds
+func ListMatches(ctx context.Context, db *sql.DB, discipline string) ([]models.Match, error) {
+	return queryMatches(ctx, db, `SELECT `+matchColumns+` FROM matches
+		WHERE discipline = $1
+		ORDER BY stage, group_name, round, slot`, discipline)
+}
+
+// GetMatch loads a single match by ID
+func GetMatch(ctx context.Context, db *sql.DB, id int64) (*models.Match, error) {
+	var m models.Match
+	row := db.QueryRowContext(ctx, `SELECT `+matchColumns+` FROM matches WHERE id = $1`, id)
+	if err := scanMatch(row, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+const updateMatchQuery = `
+	UPDATE matches SET player1 = $2, player2 = $3, score1 = $4, score2 = $5,
+		winner = $6, status = $7, reported_by = $8, updated_at = now()
+	WHERE id = $1`
+
+func updateMatch(ctx context.Context, tx *sql.Tx, query string, m models.Match) (sql.Result, error) {
+	return tx.ExecContext(ctx, query, m.ID, m.Player1, m.Player2, m.Score1, m.Score2, m.Winner, m.Status, m.ReportedBy)
+}
+
+// UpdateMatches saves players, scores and status of the given matches in one transaction
+func UpdateMatches(ctx context.Context, db *sql.DB, matches ...models.Match) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range matches {
+		if _, err := updateMatch(ctx, tx, updateMatchQuery, m); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordResult saves the finished match and the matches its result changed
+// (the next playoff round, a seeded playoff) in one transaction. Returns
+// sql.ErrNoRows if the result of the match has already been recorded
+func RecordResult(ctx context.Context, db *sql.DB, done models.Match, changed ...models.Match) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := updateMatch(ctx, tx, updateMatchQuery+` AND status <> 'done'`, done)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(res); err != nil {
+		return err
+	}
+	for _, m := range changed {
+		if _, err := updateMatch(ctx, tx, updateMatchQuery, m); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetMatchStart sets when the match is played; a zero time clears it.
+// Returns sql.ErrNoRows if there is no such match
+func SetMatchStart(ctx context.Context, db *sql.DB, id int64, startsAt time.Time) error {
+	var value sql.NullTime
+	if !startsAt.IsZero() {
+		value = sql.NullTime{Time: startsAt, Valid: true}
+	}
+	res, err := db.ExecContext(ctx, `UPDATE matches SET starts_at = $2, updated_at = now() WHERE id = $1`, id, value)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// ListPlayerMatches returns all matches of the player with a known opponent, oldest first
+func ListPlayerMatches(ctx context.Context, db *sql.DB, tgID int64) ([]models.Match, error) {
+	return queryMatches(ctx, db, `SELECT `+matchColumns+` FROM matches
+		WHERE (player1 = $1 OR player2 = $1) AND player1 <> 0 AND player2 <> 0
+		ORDER BY discipline, stage, round, slot`, tgID)
+}
+
+func queryMatches(ctx context.Context, db *sql.DB, query string, args ...any) ([]models.Match, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []models.Match
+	for rows.Next() {
+		var m models.Match
+		if err := scanMatch(rows, &m); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// MatchRepository exposes the matches table to code that takes its
+// dependencies as interfaces
+type MatchRepository struct {
+	db *sql.DB
+}
+
+func NewMatchRepository(db *sql.DB) *MatchRepository {
+	return &MatchRepository{db: db}
+}
+
+func (r *MatchRepository) Replace(ctx context.Context, discipline string, matches []models.Match) error {
+	return ReplaceMatches(ctx, r.db, discipline, matches)
+}
+
+func (r *MatchRepository) List(ctx context.Context, discipline string) ([]models.Match, error) {
+	return ListMatches(ctx, r.db, discipline)
+}
+
+func (r *MatchRepository) Get(ctx context.Context, id int64) (*models.Match, error) {
+	return GetMatch(ctx, r.db, id)
+}
+
+func (r *MatchRepository) Update(ctx context.Context, matches ...models.Match) error {
+	return UpdateMatches(ctx, r.db, matches...)
+}
+
+func (r *MatchRepository) RecordResult(ctx context.Context, done models.Match, changed ...models.Match) error {
+	return RecordResult(ctx, r.db, done, changed...)
+}
+
+func (r *MatchRepository) SetStart(ctx context.Context, id int64, startsAt time.Time) error {
+	return SetMatchStart(ctx, r.db, id, startsAt)
+}
+
+func (r *MatchRepository) ByPlayer(ctx context.Context, tgID int64) ([]models.Match, error) {
+	return ListPlayerMatches(ctx, r.db, tgID)
+}
@@ -1,13 +1,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 )
 
-// migrate creates necessary tables if they don't exist
-func migrate(db *sql.DB) error {
-	_, err := db.Exec(`
+// Migration is a single versioned schema change with its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrations lists all schema changes in order. Never edit an applied step,
+// append a new one with the next version instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_users",
+		Up: `
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     tg_id BIGINT UNIQUE,
@@ -15,10 +29,382 @@ CREATE TABLE IF NOT EXISTS users (
     last_name TEXT,
     class TEXT,
     disciplines JSONB
+);`,
+		Down: `DROP TABLE IF EXISTS users;`,
+	},
+	{
+		Version: 2,
+		Name:    "create_sessions",
+		Up: `
+CREATE TABLE IF NOT EXISTS sessions (
+    tg_id BIGINT PRIMARY KEY,
+    state TEXT NOT NULL,
+    current_game TEXT NOT NULL DEFAULT '',
+    tri_games JSONB,
+    temp JSONB,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+		Down: `DROP TABLE IF EXISTS sessions;`,
+	},
+	{
+		Version: 3,
+		Name:    "users_timestamps",
+		Up: `
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();`,
+		Down: `
+ALTER TABLE users
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at;`,
+	},
+	{
+		Version: 4,
+		Name:    "create_matches",
+		Up: `
+CREATE TABLE IF NOT EXISTS matches (
+    id SERIAL PRIMARY KEY,
+    discipline TEXT NOT NULL,
+    stage TEXT NOT NULL,
+    group_name TEXT NOT NULL DEFAULT '',
+    round INT NOT NULL,
+    slot INT NOT NULL,
+    source1 TEXT NOT NULL DEFAULT '',
+    source2 TEXT NOT NULL DEFAULT '',
+    player1 BIGINT NOT NULL DEFAULT 0,
+    player2 BIGINT NOT NULL DEFAULT 0,
+    score1 INT NOT NULL DEFAULT 0,
+    score2 INT NOT NULL DEFAULT 0,
+    winner BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS matches_discipline_idx ON matches (discipline);`,
+		Down: `DROP TABLE IF EXISTS matches;`,
+	},
+	{
+		Version: 5,
+		Name:    "matches_reported_by",
+		Up:      `ALTER TABLE matches ADD COLUMN IF NOT EXISTS reported_by BIGINT NOT NULL DEFAULT 0;`,
+		Down:    `ALTER TABLE matches DROP COLUMN IF EXISTS reported_by;`,
+	},
+	{
+		Version: 6,
+		Name:    "create_settings",
+		Up: `
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+		Down: `DROP TABLE IF EXISTS settings;`,
+	},
+	{
+		Version: 7,
+		Name:    "sessions_mode",
+		Up:      `ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT '';`,
+		Down:    `ALTER TABLE sessions DROP COLUMN IF EXISTS mode;`,
+	},
+	{
+		Version: 8,
+		Name:    "users_withdrawal",
+		Up: `
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS withdrawn_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS withdrawn JSONB NOT NULL DEFAULT '{}';`,
+		Down: `
+ALTER TABLE users
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS withdrawn_at,
+    DROP COLUMN IF EXISTS withdrawn;`,
+	},
+	{
+		Version: 9,
+		Name:    "create_admins",
+		Up: `
+CREATE TABLE IF NOT EXISTS admins (
+    tg_id BIGINT PRIMARY KEY,
+    role TEXT NOT NULL DEFAULT 'admin',
+    added_by BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+		Down: `DROP TABLE IF EXISTS admins;`,
+	},
+	{
+		Version: 10,
+		Name:    "admins_role_check",
+		Up: `
+ALTER TABLE admins ADD CONSTRAINT admins_role_check
+    CHECK (role IN ('owner', 'admin', 'moderator', 'referee'));`,
+		Down: `ALTER TABLE admins DROP CONSTRAINT IF EXISTS admins_role_check;`,
+	},
+	{
+		Version: 11,
+		Name:    "create_broadcasts",
+		Up: `
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id SERIAL PRIMARY KEY,
+    author_id BIGINT NOT NULL,
+    target TEXT NOT NULL,
+    target_value TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    file_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+    broadcast_id INTEGER NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    tg_id BIGINT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ,
+    PRIMARY KEY (broadcast_id, tg_id)
+);`,
+		Down: `
+DROP TABLE IF EXISTS broadcast_deliveries;
+DROP TABLE IF EXISTS broadcasts;`,
+	},
+	{
+		Version: 12,
+		Name:    "create_waitlist",
+		Up: `
+CREATE TABLE IF NOT EXISTS waitlist (
+    discipline TEXT NOT NULL,
+    tg_id BIGINT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (discipline, tg_id)
+);`,
+		Down: `DROP TABLE IF EXISTS waitlist;`,
+	},
+	{
+		Version: 13,
+		Name:    "waitlist_order_and_offers",
+		Up: `
+ALTER TABLE waitlist
+    ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS offer_expires_at TIMESTAMPTZ;
+UPDATE waitlist w SET position = r.n
+FROM (
+    SELECT discipline, tg_id, row_number() OVER (PARTITION BY discipline ORDER BY created_at, tg_id) AS n
+    FROM waitlist
+) r
+WHERE w.discipline = r.discipline AND w.tg_id = r.tg_id;`,
+		Down: `
+ALTER TABLE waitlist
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS offer_expires_at;`,
+	},
+	{
+		Version: 14,
+		Name:    "create_checkins",
+		Up: `
+CREATE TABLE IF NOT EXISTS checkin_windows (
+    discipline TEXT PRIMARY KEY,
+    opens_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closes_at TIMESTAMPTZ NOT NULL,
+    remind_every INT NOT NULL DEFAULT 0,
+    reminded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS checkins (
+    discipline TEXT NOT NULL,
+    tg_id BIGINT NOT NULL,
+    checked_in_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (discipline, tg_id)
+);`,
+		Down: `
+DROP TABLE IF EXISTS checkins;
+DROP TABLE IF EXISTS checkin_windows;`,
+	},
+	{
+		Version: 15,
+		Name:    "create_jobs",
+		Up: `
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    key TEXT UNIQUE,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    run_at TIMESTAMPTZ NOT NULL,
+    every INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    done_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS jobs_due_idx ON jobs (run_at) WHERE done_at IS NULL;`,
+		Down: `DROP TABLE IF EXISTS jobs;`,
+	},
+	{
+		Version: 16,
+		Name:    "matches_starts_at",
+		Up:      `ALTER TABLE matches ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;`,
+		Down:    `ALTER TABLE matches DROP COLUMN IF EXISTS starts_at;`,
+	},
+	{
+		Version: 17,
+		Name:    "sessions_activity",
+		Up: `
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS last_active_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMPTZ;
+UPDATE sessions SET last_active_at = updated_at;
+CREATE INDEX IF NOT EXISTS sessions_last_active_idx ON sessions (last_active_at);`,
+		Down: `
+DROP INDEX IF EXISTS sessions_last_active_idx;
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS last_active_at,
+    DROP COLUMN IF EXISTS reminded_at;`,
+	},
+	{
+		// Before roles every organizer had full rights. Without an owner nobody
+		// could grant roles, so the old organizers become owners
+		Version: 18,
+		Name:    "promote_legacy_admins",
+		Up: `
+UPDATE admins SET role = 'owner'
+WHERE role = 'admin' AND NOT EXISTS (SELECT 1 FROM admins WHERE role = 'owner');`,
+		// Which rows were promoted is not recorded, so roles are left as they are
+		Down: `SELECT 1;`,
+	},
+	{
+		Version: 19,
+		Name:    "sessions_draft",
+		Up:      `ALTER TABLE sessions ADD COLUMN IF NOT EXISTS draft JSONB;`,
+		Down:    `ALTER TABLE sessions DROP COLUMN IF EXISTS draft;`,
+	},
+	{
+		Version: 20,
+		Name:    "sessions_verifying",
+		Up:      `ALTER TABLE sessions ADD COLUMN IF NOT EXISTS verifying TEXT NOT NULL DEFAULT '';`,
+		Down:    `ALTER TABLE sessions DROP COLUMN IF EXISTS verifying;`,
+	},
+}
+
+// migrate applies all pending migrations and refuses to run against a schema
+// that is newer than this binary knows about
+func migrate(ctx context.Context, db *sql.DB) error {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	latest := latestVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is ahead of this binary (latest known %d)", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			log.Printf("migrate error: %v", err)
+			return err
+		}
+		log.Printf("migration %d_%s applied", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first
+func Rollback(ctx context.Context, db *sql.DB, steps int) error {
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return err
+	}
+	for i := 0; i < steps; i++ {
+		current, err := schemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return nil
+		}
+		m, ok := findMigration(current)
+		if !ok {
+			return fmt.Errorf("migration %d is unknown to this binary", current)
+		}
+		if err := revertMigration(ctx, db, m); err != nil {
+			return err
+		}
+		log.Printf("migration %d_%s reverted", m.Version, m.Name)
+	}
+	return nil
+}
+
+func ensureMigrationsTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 `)
 	if err != nil {
-		log.Printf("migrate error: %v", err)
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// schemaVersion returns the highest applied migration version (0 for an empty DB)
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return v, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return fmt.Errorf("record migration %d: %w", m.Version, err)
+	}
+	return tx.Commit()
+}
+
+func revertMigration(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+		return fmt.Errorf("unrecord migration %d: %w", m.Version, err)
+	}
+	return tx.Commit()
+}
+
+func latestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func findMigration(version int) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
 	}
-	return err
+	return Migration{}, false
 }
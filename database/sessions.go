@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"tgbot/states"
+)
+
+// SessionStore keeps FSM sessions in the sessions table (implements states.Store)
+type SessionStore struct {
+	db *sql.DB
+}
+
+func NewSessionStore(db *sql.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+// Load returns the saved session of the user or nil if there is none
+func (s *SessionStore) Load(userID int64) (*states.Session, error) {
+	var (
+		state, game, mode string
+		verifying         string
+		triJSON, tmpJSON  []byte
+		draftJSON         []byte
+		lastActive        time.Time
+		reminded          sql.NullTime
+	)
+	err := s.db.QueryRow(`
+		SELECT state, current_game, tri_games, temp, mode, last_active_at, reminded_at, draft, verifying
+		FROM sessions WHERE tg_id = $1
+	`, userID).Scan(&state, &game, &triJSON, &tmpJSON, &mode, &lastActive, &reminded, &draftJSON, &verifying)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &states.Session{State: states.State(state), CurrentGame: game, Mode: mode, LastActive: lastActive, RemindedAt: reminded.Time, Verifying: verifying}
+	if len(triJSON) > 0 {
+		if err := json.Unmarshal(triJSON, &sess.TriGames); err != nil {
+			return nil, err
+		}
+	}
+	if len(tmpJSON) > 0 {
+		if err := json.Unmarshal(tmpJSON, &sess.Temp); err != nil {
+			return nil, err
+		}
+	}
+	if len(draftJSON) > 0 {
+		if err := json.Unmarshal(draftJSON, &sess.Draft); err != nil {
+			return nil, err
+		}
+	}
+	return sess, nil
+}
+
+// Save inserts or updates the session of the user (upsert on tg_id)
+func (s *SessionStore) Save(userID int64, sess *states.Session) error {
+	triJSON, err := json.Marshal(sess.TriGames)
+	if err != nil {
+		return err
+	}
+	tmpJSON, err := json.Marshal(sess.Temp)
+	if err != nil {
+		return err
+	}
+
+	// No draft is stored as NULL rather than a JSON null
+	var draft sql.NullString
+	if sess.Draft != nil {
+		draftJSON, err := json.Marshal(sess.Draft)
+		if err != nil {
+			return err
+		}
+		draft = sql.NullString{String: string(draftJSON), Valid: true}
+	}
+
+	var reminded sql.NullTime
+	if !sess.RemindedAt.IsZero() {
+		reminded = sql.NullTime{Time: sess.RemindedAt, Valid: true}
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO sessions (tg_id, state, current_game, tri_games, temp, mode, last_active_at, reminded_at, draft, verifying, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())
+		ON CONFLICT (tg_id) DO UPDATE SET
+			state = EXCLUDED.state,
+			current_game = EXCLUDED.current_game,
+			tri_games = EXCLUDED.tri_games,
+			temp = EXCLUDED.temp,
+			mode = EXCLUDED.mode,
+			last_active_at = EXCLUDED.last_active_at,
+			reminded_at = EXCLUDED.reminded_at,
+			draft = EXCLUDED.draft,
+			verifying = EXCLUDED.verifying,
+			updated_at = EXCLUDED.updated_at
+	`, userID, string(sess.State), sess.CurrentGame, triJSON, tmpJSON, sess.Mode, sess.LastActive, reminded, draft, sess.Verifying)
+	return err
+}
+
+// Delete removes the saved session of the user
+func (s *SessionStore) Delete(userID int64) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE tg_id = $1`, userID)
+	return err
+}
+
+// MarkReminded sets reminded_at of the saved session, if there is one
+func (s *SessionStore) MarkReminded(userID int64, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE sessions SET reminded_at = $2 WHERE tg_id = $1`, userID, at)
+	return err
+}
+
+// Unfinished lists users in the middle of a form, longest idle first
+func (s *SessionStore) Unfinished() ([]int64, error) {
+	return s.ids(`
+		SELECT tg_id FROM sessions
+		WHERE state <> $1
+		ORDER BY last_active_at
+	`, string(states.StateIdle))
+}
+
+// Stale lists users with an unfinished session, inactive since before and not reminded yet
+func (s *SessionStore) Stale(before time.Time) ([]int64, error) {
+	return s.ids(`
+		SELECT tg_id FROM sessions
+		WHERE state <> $1 AND last_active_at <= $2 AND reminded_at IS NULL
+		ORDER BY last_active_at
+	`, string(states.StateIdle), before)
+}
+
+// Expire deletes unfinished sessions inactive since before and returns their users
+func (s *SessionStore) Expire(before time.Time) ([]int64, error) {
+	return s.ids(`
+		DELETE FROM sessions
+		WHERE state <> $1 AND last_active_at <= $2
+		RETURNING tg_id
+	`, string(states.StateIdle), before)
+}
+
+func (s *SessionStore) ids(query string, args ...any) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
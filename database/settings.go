@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GetSetting returns the value of an admin-configured setting and whether it is set
+func GetSetting(ctx context.Context, db *sql.DB, key string) (string, bool, error) {
+	var value string
+	err := db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// SetSetting stores a setting (upsert on key)
+func SetSetting(ctx context.Context, db *sql.DB, key, value string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at
+	`, key, value)
+	return err
+}
+
+// DeleteSetting removes a setting
+func DeleteSetting(ctx context.Context, db *sql.DB, key string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM settings WHERE key = $1`, key)
+	return err
+}
+
+// SettingsStore exposes the settings table to code that takes its
+// dependencies as interfaces
+type SettingsStore struct {
+	db *sql.DB
+}
+
+func NewSettingsStore(db *sql.DB) *SettingsStore {
+	return &SettingsStore{db: db}
+}
+
+func (s *SettingsStore) Get(ctx context.Context, key string) (string, bool, error) {
+	return GetSetting(ctx, s.db, key)
+}
+
+func (s *SettingsStore) Set(ctx context.Context, key, value string) error {
+	return SetSetting(ctx, s.db, key, value)
+}
+
+func (s *SettingsStore) Delete(ctx context.Context, key string) error {
+	return DeleteSetting(ctx, s.db, key)
+}
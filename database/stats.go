@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+// RegistrationStats is a summary of registrations for the admin console
+type RegistrationStats struct {
+	Active       int            // участники с хотя бы одной дисциплиной
+	Withdrawn    int            // снялись с турнира
+	InProgress   int            // незавершённые регистрации (сессии)
+	Triathlon    int            // зарегистрированы во всех играх триатлона
+	ByDiscipline map[string]int // количество участников по дисциплинам
+}
+
+// Stats counts users by status and discipline; triathlon lists the games
+// every triathlete must be registered in
+func (r *UserRepository) Stats(ctx context.Context, triathlon []string) (*RegistrationStats, error) {
+	st := &RegistrationStats{}
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status <> 'withdrawn'),
+			COUNT(*) FILTER (WHERE status = 'withdrawn'),
+			COUNT(*) FILTER (WHERE cardinality($1::text[]) > 0 AND disciplines ?& $1::text[])
+		FROM users
+	`, pq.Array(triathlon)).Scan(&st.Active, &st.Withdrawn, &st.Triathlon)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE state <> 'idle'`).Scan(&st.InProgress); err != nil {
+		return nil, err
+	}
+
+	st.ByDiscipline, err = r.CountByDiscipline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
@@ -1,28 +1,376 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"tgbot/models"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-// SaveUser inserts or updates a user record (upsert on tg_id)
-func SaveUser(db *sql.DB, u *models.User) error {
+// UserRepository reads and writes participant registrations in the users table
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// UserFilter narrows List down; zero fields don't filter
+type UserFilter struct {
+	Discipline string    // registered in this game (disciplines ? key)
+	Class      string    // class, case-insensitive
+	Status     string    // models.UserActive or models.UserWithdrawn
+	From       time.Time // registered at or after
+	To         time.Time // registered before
+}
+
+// where builds the WHERE clause and its arguments, numbering placeholders from 1
+func (f UserFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Discipline != "" {
+		add("disciplines ? $%d", f.Discipline)
+	}
+	if f.Class != "" {
+		add("lower(class) = lower($%d)", f.Class)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// Save inserts or updates a user record (upsert on tg_id)
+func (r *UserRepository) Save(ctx context.Context, u *models.User) error {
 	disciplinesJSON, err := json.Marshal(u.Disciplines)
 	if err != nil {
 		return err
 	}
 
-	err = db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
 		INSERT INTO users (tg_id, first_name, last_name, class, disciplines)
 		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (tg_id) DO UPDATE SET
 			first_name = EXCLUDED.first_name,
 			last_name = EXCLUDED.last_name,
 			class = EXCLUDED.class,
-			disciplines = EXCLUDED.disciplines
+			disciplines = EXCLUDED.disciplines,
+			status = 'active',
+			withdrawn_at = NULL,
+			updated_at = now()
 		RETURNING id
 	`, u.TelegramID, u.FirstName, u.LastName, u.Class, disciplinesJSON).Scan(&u.ID)
 
 	return err
-}
\ No newline at end of file
+}
+
+const userColumns = `id, tg_id, first_name, last_name, class, disciplines, status, created_at`
+
+func scanUser(row interface{ Scan(...any) error }, u *models.User) error {
+	var disciplinesJSON []byte
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.FirstName, &u.LastName, &u.Class, &disciplinesJSON, &u.Status, &u.CreatedAt); err != nil {
+		return err
+	}
+	if len(disciplinesJSON) > 0 {
+		if err := json.Unmarshal(disciplinesJSON, &u.Disciplines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryUsers(ctx context.Context, db *sql.DB, query string, args ...any) ([]models.User, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetByTelegramID loads a user by Telegram ID; returns sql.ErrNoRows if not registered
+func (r *UserRepository) GetByTelegramID(ctx context.Context, tgID int64) (*models.User, error) {
+	u := &models.User{}
+	row := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE tg_id = $1`, tgID)
+	if err := scanUser(row, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// GetByID loads a user by the row ID; returns sql.ErrNoRows if there is none
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	u := &models.User{}
+	row := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	if err := scanUser(row, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// List returns a page of users matching the filter, oldest first, and the total
+// number of matches. limit <= 0 returns all of them
+func (r *UserRepository) List(ctx context.Context, f UserFilter, limit, offset int) ([]models.User, int, error) {
+	where, args := f.where()
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where + ` ORDER BY id`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, limit, offset)
+	}
+	users, err := queryUsers(ctx, r.db, query, args...)
+	return users, total, err
+}
+
+// CountByDiscipline returns the number of users registered in each game
+func (r *UserRepository) CountByDiscipline(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT game, COUNT(*)
+		FROM users, jsonb_object_keys(disciplines) AS game
+		GROUP BY game
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var game string
+		var n int
+		if err := rows.Scan(&game, &n); err != nil {
+			return nil, err
+		}
+		counts[game] = n
+	}
+	return counts, rows.Err()
+}
+
+// FindByGameField returns active users whose field (nick or tag) in the game
+// equals value, ignoring case
+func (r *UserRepository) FindByGameField(ctx context.Context, game, field, value string) ([]models.User, error) {
+	return queryUsers(ctx, r.db, `SELECT `+userColumns+` FROM users
+		WHERE status = 'active' AND lower(disciplines->$1::text->>$2::text) = lower($3)
+		ORDER BY id`, game, field, value)
+}
+
+// Conflicts finds nicks and tags shared by several active users in the same game.
+// Both fields are reported; which one identifies a player is up to the caller
+func (r *UserRepository) Conflicts(ctx context.Context) ([]models.Conflict, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT d.key, f.field, min(d.value->>f.field), array_agg(u.tg_id ORDER BY u.id)
+		FROM users u, jsonb_each(u.disciplines) d, unnest(ARRAY['nick', 'tag']) AS f(field)
+		WHERE u.status = 'active' AND COALESCE(d.value->>f.field, '') <> ''
+		GROUP BY d.key, f.field, lower(d.value->>f.field)
+		HAVING COUNT(*) > 1
+		ORDER BY d.key, f.field, lower(d.value->>f.field)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []models.Conflict
+	for rows.Next() {
+		var c models.Conflict
+		if err := rows.Scan(&c.Discipline, &c.Field, &c.Value, pq.Array(&c.TelegramIDs)); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// userSearchCond matches first/last name or any nick/tag inside disciplines
+const userSearchCond = `first_name ILIKE $1 OR last_name ILIKE $1
+	OR EXISTS (
+		SELECT 1 FROM jsonb_each(disciplines) d
+		WHERE d.value->>'nick' ILIKE $1 OR d.value->>'tag' ILIKE $1
+	)`
+
+// Search finds users by name, nick or tag (case-insensitive substring) and returns a page and the total count
+func (r *UserRepository) Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+userSearchCond, pattern).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	users, err := queryUsers(ctx, r.db, `SELECT `+userColumns+` FROM users
+		WHERE `+userSearchCond+`
+		ORDER BY id LIMIT $2 OFFSET $3`, pattern, limit, offset)
+	return users, total, err
+}
+
+// Delete removes the user row, the saved session and the check-ins for good.
+// Matches stay in the brackets, but the user's unfinished ones are marked
+// disputed so that an organizer settles them
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tgID int64
+	if err := tx.QueryRowContext(ctx, `DELETE FROM users WHERE id = $1 RETURNING tg_id`, id).Scan(&tgID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE tg_id = $1`, tgID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checkins WHERE tg_id = $1`, tgID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE matches SET status = 'disputed', updated_at = now()
+		WHERE status <> 'done' AND $1 IN (player1, player2)
+	`, tgID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// profileColumns are the personal fields a user can change with SetProfileField
+var profileColumns = map[string]bool{"first_name": true, "last_name": true, "class": true}
+
+// SetProfileField changes one personal field of the user (first_name,
+// last_name or class) and leaves the rest of the record as it is in the table;
+// returns sql.ErrNoRows if the user is gone
+func (r *UserRepository) SetProfileField(ctx context.Context, tgID int64, field, value string) error {
+	if !profileColumns[field] {
+		return fmt.Errorf("unknown profile field %q", field)
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET `+field+` = $2, updated_at = now() WHERE tg_id = $1`, tgID, value)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// SetDiscipline replaces the data of one game the user is registered in,
+// keeping the other games; returns sql.ErrNoRows if the user is gone or no
+// longer plays the game
+func (r *UserRepository) SetDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error {
+	data, err := json.Marshal(gd)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET
+			disciplines = jsonb_set(disciplines, ARRAY[$2::text], $3::jsonb),
+			updated_at = now()
+		WHERE tg_id = $1 AND disciplines ? $2::text
+	`, tgID, game, data)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// WithdrawDiscipline moves the game from disciplines to the withdrawn archive with
+// a timestamp; a user left without disciplines gets the withdrawn status
+func (r *UserRepository) WithdrawDiscipline(ctx context.Context, tgID int64, game string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET
+			withdrawn = withdrawn || jsonb_build_object($2::text, disciplines->$2 || jsonb_build_object('withdrawn_at', now())),
+			disciplines = disciplines - $2::text,
+			status = CASE WHEN disciplines - $2::text = '{}'::jsonb THEN 'withdrawn' ELSE status END,
+			withdrawn_at = CASE WHEN disciplines - $2::text = '{}'::jsonb THEN now() ELSE withdrawn_at END,
+			updated_at = now()
+		WHERE tg_id = $1 AND disciplines ? $2::text
+	`, tgID, game)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// AddDiscipline registers an existing user in one more game, bringing a
+// withdrawn user back; returns sql.ErrNoRows if the user is gone
+func (r *UserRepository) AddDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error {
+	data, err := json.Marshal(gd)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET
+			disciplines = disciplines || jsonb_build_object($2::text, $3::jsonb),
+			status = 'active',
+			withdrawn_at = NULL,
+			updated_at = now()
+		WHERE tg_id = $1
+	`, tgID, game, data)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// Withdraw archives all disciplines of the user and marks them withdrawn
+func (r *UserRepository) Withdraw(ctx context.Context, tgID int64) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET
+			withdrawn = withdrawn || COALESCE((
+				SELECT jsonb_object_agg(key, value || jsonb_build_object('withdrawn_at', now()))
+				FROM jsonb_each(disciplines)
+			), '{}'::jsonb),
+			disciplines = '{}'::jsonb,
+			status = 'withdrawn',
+			withdrawn_at = now(),
+			updated_at = now()
+		WHERE tg_id = $1 AND status <> 'withdrawn'
+	`, tgID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// expectAffected returns sql.ErrNoRows when the statement changed nothing
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"tgbot/models"
+)
+
+// WaitlistRepository keeps the ordered queues of participants waiting for a
+// spot in a full discipline
+type WaitlistRepository struct {
+	db *sql.DB
+}
+
+func NewWaitlistRepository(db *sql.DB) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+// Add puts the user at the end of the queue of the game. A user who is already
+// waiting keeps their place and only gets the nick and tag updated
+func (r *WaitlistRepository) Add(ctx context.Context, game string, tgID int64, gd models.GameData) error {
+	data, err := json.Marshal(gd)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO waitlist (discipline, tg_id, data, position)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM waitlist WHERE discipline = $1))
+		ON CONFLICT (discipline, tg_id) DO UPDATE SET data = EXCLUDED.data
+	`, game, tgID, data)
+	return err
+}
+
+// Get returns the entry of the user in the queue of the game; sql.ErrNoRows if the user is not waiting
+func (r *WaitlistRepository) Get(ctx context.Context, game string, tgID int64) (*models.WaitlistEntry, error) {
+	e := &models.WaitlistEntry{}
+	row := r.db.QueryRowContext(ctx, `SELECT `+waitlistColumns+` FROM waitlist
+		WHERE discipline = $1 AND tg_id = $2`, game, tgID)
+	if err := scanWaitlistEntry(row, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// List returns the queue of the game in order
+func (r *WaitlistRepository) List(ctx context.Context, game string) ([]models.WaitlistEntry, error) {
+	return r.query(ctx, `SELECT `+waitlistColumns+` FROM waitlist
+		WHERE discipline = $1
+		ORDER BY position, created_at`, game)
+}
+
+// ByUser returns all queues the user is waiting in
+func (r *WaitlistRepository) ByUser(ctx context.Context, tgID int64) ([]models.WaitlistEntry, error) {
+	return r.query(ctx, `SELECT `+waitlistColumns+` FROM waitlist
+		WHERE tg_id = $1
+		ORDER BY discipline`, tgID)
+}
+
+// Expired returns the offers that were not accepted before now
+func (r *WaitlistRepository) Expired(ctx context.Context, now time.Time) ([]models.WaitlistEntry, error) {
+	return r.query(ctx, `SELECT `+waitlistColumns+` FROM waitlist
+		WHERE offer_expires_at <= $1
+		ORDER BY discipline, position`, now)
+}
+
+// Offer reserves a spot for the user until expires
+func (r *WaitlistRepository) Offer(ctx context.Context, game string, tgID int64, expires time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE waitlist SET offer_expires_at = $3
+		WHERE discipline = $1 AND tg_id = $2
+	`, game, tgID, expires)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// Move puts the user at the given place (from 1) in the queue of the game and
+// renumbers the rest; a place past the end moves the user to the end
+func (r *WaitlistRepository) Move(ctx context.Context, game string, tgID int64, position int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT tg_id FROM waitlist WHERE discipline = $1
+		ORDER BY position, created_at
+		FOR UPDATE
+	`, game)
+	if err != nil {
+		return err
+	}
+	var order []int64
+	found := false
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if id == tgID {
+			found = true
+			continue
+		}
+		order = append(order, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	if position < 1 {
+		position = 1
+	}
+	if position > len(order)+1 {
+		position = len(order) + 1
+	}
+	order = append(order[:position-1], append([]int64{tgID}, order[position-1:]...)...)
+	for i, id := range order {
+		if _, err := tx.ExecContext(ctx, `UPDATE waitlist SET position = $3 WHERE discipline = $1 AND tg_id = $2`, game, id, i+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Remove takes the user out of the queue of the game
+func (r *WaitlistRepository) Remove(ctx context.Context, game string, tgID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM waitlist WHERE discipline = $1 AND tg_id = $2`, game, tgID)
+	return err
+}
+
+// RemoveUser takes the user out of every queue
+func (r *WaitlistRepository) RemoveUser(ctx context.Context, tgID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM waitlist WHERE tg_id = $1`, tgID)
+	return err
+}
+
+const waitlistColumns = `discipline, tg_id, data, position, offer_expires_at, created_at`
+
+func (r *WaitlistRepository) query(ctx context.Context, query string, args ...any) ([]models.WaitlistEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.WaitlistEntry
+	for rows.Next() {
+		var e models.WaitlistEntry
+		if err := scanWaitlistEntry(rows, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func scanWaitlistEntry(row interface{ Scan(...any) error }, e *models.WaitlistEntry) error {
+	var data []byte
+	var expires sql.NullTime
+	if err := row.Scan(&e.Discipline, &e.TelegramID, &data, &e.Position, &expires, &e.CreatedAt); err != nil {
+		return err
+	}
+	e.OfferExpiresAt = expires.Time
+	return json.Unmarshal(data, &e.Data)
+}
@@ -0,0 +1,89 @@
+// Package dispatcher processes Telegram updates on a pool of workers while
+// keeping the updates of each user in the order they arrived.
+package dispatcher
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Handler processes a single update
+type Handler func(update tgbotapi.Update)
+
+// Dispatcher routes every update to one of the worker shards by sender ID.
+// One worker owns one shard, so updates of the same user are never handled
+// concurrently or out of order, while different users proceed in parallel.
+type Dispatcher struct {
+	shards []chan tgbotapi.Update
+	handle Handler
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// New creates a dispatcher with the given number of workers; each worker has
+// a queue of queueSize updates, Dispatch blocks when the queue is full
+func New(workers, queueSize int, handle Handler) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &Dispatcher{shards: make([]chan tgbotapi.Update, workers), handle: handle}
+	for i := range d.shards {
+		d.shards[i] = make(chan tgbotapi.Update, queueSize)
+	}
+	return d
+}
+
+// Start launches the workers
+func (d *Dispatcher) Start() {
+	for _, shard := range d.shards {
+		d.wg.Add(1)
+		go d.work(shard)
+	}
+}
+
+// Dispatch queues the update on the shard of its sender. Must not be called after Stop.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) {
+	d.shards[d.shardOf(update)] <- update
+}
+
+// Stop stops accepting updates and waits until all queued ones are handled
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() {
+		for _, shard := range d.shards {
+			close(shard)
+		}
+	})
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) work(shard <-chan tgbotapi.Update) {
+	defer d.wg.Done()
+	for update := range shard {
+		d.safeHandle(update)
+	}
+}
+
+// safeHandle runs the handler and keeps the worker alive if it panics
+func (d *Dispatcher) safeHandle(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic while handling update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+	d.handle(update)
+}
+
+// shardOf picks a shard by sender ID; updates without a sender are spread by update ID
+func (d *Dispatcher) shardOf(update tgbotapi.Update) int {
+	key := int64(update.UpdateID)
+	if from := update.SentFrom(); from != nil {
+		key = from.ID
+	}
+	if key < 0 {
+		key = -key
+	}
+	return int(key % int64(len(d.shards)))
+}
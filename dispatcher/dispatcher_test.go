@@ -0,0 +1,118 @@
+package dispatcher
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func message(updateID int, userID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: updateID, Message: &tgbotapi.Message{From: &tgbotapi.User{ID: userID}}}
+}
+
+func callback(updateID int, userID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: updateID, CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: userID}}}
+}
+
+func TestPerUserOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		users   int
+		perUser int
+	}{
+		{name: "single worker", workers: 1, users: 5, perUser: 50},
+		{name: "more users than workers", workers: 4, users: 20, perUser: 50},
+		{name: "more workers than users", workers: 16, users: 3, perUser: 100},
+		{name: "zero workers fall back to one", workers: 0, users: 3, perUser: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			got := make(map[int64][]int)
+			busy := make(map[int64]bool)
+			d := New(tt.workers, 8, func(u tgbotapi.Update) {
+				id := u.SentFrom().ID
+				mu.Lock()
+				if busy[id] {
+					t.Errorf("user %d handled concurrently", id)
+				}
+				busy[id] = true
+				mu.Unlock()
+
+				// Случайная задержка перемешала бы порядок, если бы он не соблюдался
+				time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+
+				mu.Lock()
+				busy[id] = false
+				got[id] = append(got[id], u.UpdateID)
+				mu.Unlock()
+			})
+			d.Start()
+
+			want := make(map[int64][]int)
+			updateID := 0
+			for i := 0; i < tt.perUser; i++ {
+				for user := int64(1); user <= int64(tt.users); user++ {
+					updateID++
+					// Сообщения и нажатия кнопок одного пользователя идут в одну очередь
+					u := message(updateID, user)
+					if updateID%3 == 0 {
+						u = callback(updateID, user)
+					}
+					d.Dispatch(u)
+					want[user] = append(want[user], updateID)
+				}
+			}
+			d.Stop()
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("handled order differs from arrival order:\ngot  %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPanicKeepsWorker(t *testing.T) {
+	var handled []int
+	d := New(1, 4, func(u tgbotapi.Update) {
+		if u.UpdateID == 2 {
+			panic("boom")
+		}
+		handled = append(handled, u.UpdateID)
+	})
+	d.Start()
+	for id := 1; id <= 3; id++ {
+		d.Dispatch(message(id, 7))
+	}
+	d.Stop()
+	d.Stop() // повторный Stop безопасен
+
+	if want := []int{1, 3}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled = %v, want %v", handled, want)
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	d := New(4, 1, nil)
+	tests := []struct {
+		name   string
+		update tgbotapi.Update
+		want   int
+	}{
+		{name: "by sender", update: message(100, 6), want: 2},
+		{name: "callback of the same sender", update: callback(101, 6), want: 2},
+		{name: "negative ID", update: message(102, -7), want: 3},
+		{name: "no sender spreads by update ID", update: tgbotapi.Update{UpdateID: 9}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := d.shardOf(tt.update); got != tt.want {
+			t.Errorf("%s: shardOf = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
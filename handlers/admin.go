@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"tgbot/access"
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/registry"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminPageSize — количество участников на одной странице списков
+const adminPageSize = 10
+
+// HandleUsers показывает постраничный список всех участников: /users
+func HandleUsers(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	sendUsersPage(ctx, bot, deps, update.Message.Chat.ID, 0)
+}
+
+// HandleFind ищет участников по имени, фамилии, нику или тегу: /find Иван
+func HandleFind(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	query := strings.TrimSpace(update.Message.CommandArguments())
+	if query == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /find <имя|ник|тег>"))
+		return
+	}
+	sendSearchPage(ctx, bot, deps, chatID, query, 0)
+}
+
+// HandleUserCard показывает полную анкету участника: /user 42 (ID записи) или /user tg:123456789 (Telegram ID)
+func HandleUserCard(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	u, ok := findUserByArg(ctx, bot, deps, chatID, update.Message.CommandArguments(), "/user")
+	if !ok {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatUserCard(u))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("adm_del_%d", u.ID)),
+		),
+	)
+	bot.Send(msg)
+}
+
+// HandleDeleteUser запрашивает подтверждение удаления участника: /delete 42, /delete tg:123456789
+func HandleDeleteUser(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	u, ok := findUserByArg(ctx, bot, deps, chatID, update.Message.CommandArguments(), "/delete")
+	if !ok {
+		return
+	}
+	askDeleteConfirm(bot, chatID, u)
+}
+
+// HandleAdminStats показывает сводку по регистрациям: /stats
+func HandleAdminStats(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	var triathlon []string
+	for _, d := range registry.Triathlon() {
+		triathlon = append(triathlon, d.Name)
+	}
+	stats, err := deps.Users.Stats(ctx, triathlon)
+	if err != nil {
+		log.Printf("Error loading stats: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке статистики."))
+		return
+	}
+
+	text := fmt.Sprintf("📊 СТАТИСТИКА\n\n"+
+		"👥 Участников: %d\n"+
+		"🚫 Снялись: %d\n"+
+		"📝 Регистраций в процессе: %d\n\n"+
+		"🎮 По дисциплинам:\n", stats.Active, stats.Withdrawn, stats.InProgress)
+	for _, d := range registry.All() {
+		text += fmt.Sprintf("  🔸 %s: %d\n", d.Name, stats.ByDiscipline[d.Name])
+	}
+	if tri := registry.Triathlon(); len(tri) > 1 {
+		text += fmt.Sprintf("\n🏆 Триатлон (все %d игры): %d\n", len(tri), stats.Triathlon)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// handleAdminCallback обрабатывает кнопки админ-консоли:
+// adm_users_<стр>, adm_find_<стр>_<запрос>, adm_del_<id>, adm_delok_<id>, adm_delno
+func handleAdminCallback(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, data string) {
+	parts := strings.SplitN(data, "_", 4)
+	if len(parts) < 2 {
+		log.Printf("Bad admin callback: %s", data)
+		return
+	}
+
+	// Просмотр доступен модераторам, удаление — только админам
+	perm := access.ViewUsers
+	if parts[1] == "del" || parts[1] == "delok" {
+		perm = access.DeleteUsers
+	}
+	if !Can(ctx, deps, userID, perm) {
+		log.Printf("Admin callback %s denied for %d", data, userID)
+		return
+	}
+	arg := ""
+	if len(parts) > 2 {
+		arg = parts[2]
+	}
+
+	switch parts[1] {
+	case "users":
+		page, _ := strconv.Atoi(arg)
+		sendUsersPage(ctx, bot, deps, chatID, page)
+	case "find":
+		if len(parts) != 4 {
+			log.Printf("Bad admin callback: %s", data)
+			return
+		}
+		page, _ := strconv.Atoi(arg)
+		sendSearchPage(ctx, bot, deps, chatID, parts[3], page)
+	case "del":
+		id, _ := strconv.ParseInt(arg, 10, 64)
+		u, err := deps.Users.GetByID(ctx, id)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Участник #%d не найден.", id)))
+			return
+		}
+		askDeleteConfirm(bot, chatID, u)
+	case "delok":
+		id, _ := strconv.ParseInt(arg, 10, 64)
+		u, err := deps.Users.GetByID(ctx, id)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Участник #%d не найден.", id)))
+			return
+		}
+		if err := deps.Users.Delete(ctx, id); err != nil {
+			log.Printf("Error deleting user %d: %v", id, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при удалении."))
+			return
+		}
+		// Незаконченная анкета в памяти иначе пережила бы удаление
+		mgr.Reset(u.TelegramID)
+		log.Printf("Admin %d deleted user %d (tg_id %d)", userID, id, u.TelegramID)
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Участник удалён: %s", formatUserShort(u))))
+		freed := leaveWaitlists(ctx, deps, u.TelegramID)
+		for game := range u.Disciplines {
+			freed = append(freed, game)
+		}
+		for _, game := range freed {
+			promoteWaitlist(ctx, bot, deps, game)
+		}
+	case "delno":
+		bot.Send(tgbotapi.NewMessage(chatID, "Удаление отменено."))
+	default:
+		log.Printf("Unknown admin callback: %s", data)
+	}
+}
+
+// sendUsersPage отправляет страницу списка всех участников
+func sendUsersPage(ctx context.Context, bot Bot, deps *Deps, chatID int64, page int) {
+	users, total, err := deps.Users.List(ctx, database.UserFilter{}, adminPageSize, page*adminPageSize)
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участников."))
+		return
+	}
+	sendUserList(bot, chatID, "👥 Участники", users, total, page, func(p int) string {
+		return fmt.Sprintf("adm_users_%d", p)
+	})
+}
+
+// sendSearchPage отправляет страницу результатов поиска
+func sendSearchPage(ctx context.Context, bot Bot, deps *Deps, chatID int64, query string, page int) {
+	users, total, err := deps.Users.Search(ctx, query, adminPageSize, page*adminPageSize)
+	if err != nil {
+		log.Printf("Error searching users by %q: %v", query, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при поиске."))
+		return
+	}
+	// Запрос передаётся в callback data, которая ограничена 64 байтами
+	q := truncateBytes(query, 40)
+	sendUserList(bot, chatID, fmt.Sprintf("🔍 Поиск «%s»", query), users, total, page, func(p int) string {
+		return fmt.Sprintf("adm_find_%d_%s", p, q)
+	})
+}
+
+// sendUserList форматирует страницу участников с кнопками листания
+func sendUserList(bot Bot, chatID int64, title string, users []models.User, total, page int, pageData func(int) string) {
+	if total == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, title+": ничего не найдено."))
+		return
+	}
+
+	pages := (total + adminPageSize - 1) / adminPageSize
+	text := fmt.Sprintf("%s: %d (стр. %d из %d)\n\n", title, total, page+1, pages)
+	for _, u := range users {
+		text += fmt.Sprintf("#%d %s %s, %s", u.ID, u.FirstName, u.LastName, u.Class)
+		if u.Status == models.UserWithdrawn {
+			text += " 🚫"
+		}
+		text += "\n"
+		for game, gd := range u.Disciplines {
+			text += fmt.Sprintf("    %s: %s\n", game, formatGameData(game, gd))
+		}
+	}
+	text += "\nПодробнее: /user <id> или /user tg:<Telegram ID>"
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", pageData(page-1)))
+	}
+	if page+1 < pages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Вперёд ▶️", pageData(page+1)))
+	}
+	if len(nav) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(nav)
+	}
+	bot.Send(msg)
+}
+
+// askDeleteConfirm показывает анкету и просит подтвердить удаление
+func askDeleteConfirm(bot Bot, chatID int64, u *models.User) {
+	msg := tgbotapi.NewMessage(chatID, "Удалить участника безвозвратно?\n\n"+formatUserCard(u))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Да, удалить", fmt.Sprintf("adm_delok_%d", u.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Отмена", "adm_delno"),
+		),
+	)
+	bot.Send(msg)
+}
+
+// findUserByArg ищет участника по ID записи или, с префиксом tg:, по Telegram ID.
+// Без префикса число всегда считается ID записи: иначе /delete 57 мог бы
+// задеть другого человека, у которого 57 — Telegram ID
+func findUserByArg(ctx context.Context, bot Bot, deps *Deps, chatID int64, arg, command string) (*models.User, bool) {
+	arg = strings.TrimSpace(arg)
+	digits, byTelegram := strings.CutPrefix(arg, "tg:")
+	id, err := strconv.ParseInt(strings.TrimSpace(digits), 10, 64)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Использование: %s <id> или %s tg:<Telegram ID>", command, command)))
+		return nil, false
+	}
+
+	var u *models.User
+	if byTelegram {
+		u, err = deps.Users.GetByTelegramID(ctx, id)
+	} else {
+		u, err = deps.Users.GetByID(ctx, id)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Участник %s не найден.", arg)))
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error loading user %s: %v", arg, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участника."))
+		return nil, false
+	}
+	return u, true
+}
+
+// formatUserCard форматирует анкету участника для организаторов
+func formatUserCard(u *models.User) string {
+	status := "✅ участвует"
+	if u.Status == models.UserWithdrawn {
+		status = "🚫 снялся"
+	}
+	return fmt.Sprintf("👤 Участник #%d\nTelegram ID: %d\nСтатус: %s\nЗарегистрирован: %s\n\n",
+		u.ID, u.TelegramID, status, u.CreatedAt.Format("02.01.2006 15:04")) + formatUserData(u)
+}
+
+// truncateBytes обрезает строку до n байт, не разрывая символы
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"tgbot/access"
+	"tgbot/models"
+	"tgbot/registry"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// broadcastReportLimit — сколько заблокировавших бота участников перечислять в отчёте
+const broadcastReportLimit = 30
+
+// HandleBroadcast начинает составление рассылки: /broadcast
+func HandleBroadcast(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	mgr.Reset(userID)
+	s := mgr.Get(userID)
+	s.Mode = states.ModeBroadcast
+	// Черновик хранится в сессии, поэтому переживает перезапуск бота
+	s.Draft = &models.Broadcast{AuthorID: userID}
+	mgr.SetState(userID, states.StateIdle)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📢 Всем участникам", "bc_t_all")),
+	}
+	for _, d := range registry.All() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎮 "+d.Name, "bc_t_d_"+d.Code),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📚 Одному классу", "bc_t_class")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "bc_cancel")),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, "📣 РАССЫЛКА\n\nКому отправить сообщение?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+// handleBroadcastCallback обрабатывает кнопки рассылки:
+// bc_t_all, bc_t_d_<код>, bc_t_class — выбор аудитории, bc_send — отправка, bc_cancel — отмена
+func handleBroadcastCallback(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, data string) {
+	if !Can(ctx, deps, userID, access.Broadcast) {
+		log.Printf("Broadcast callback %s denied for %d", data, userID)
+		return
+	}
+	if data == "bc_cancel" {
+		cancelBroadcast(bot, mgr, userID, chatID)
+		return
+	}
+
+	s := mgr.Get(userID)
+	b := s.Draft
+	if b == nil || s.Mode != states.ModeBroadcast {
+		bot.Send(tgbotapi.NewMessage(chatID, "Черновик рассылки не найден. Начните заново с /broadcast"))
+		return
+	}
+
+	switch {
+	case data == "bc_t_all":
+		b.Target, b.TargetValue = models.TargetAll, ""
+		askBroadcastContent(bot, mgr, userID, chatID)
+	case strings.HasPrefix(data, "bc_t_d_"):
+		d, ok := registry.ByCode(strings.TrimPrefix(data, "bc_t_d_"))
+		if !ok {
+			log.Printf("Unknown game code in broadcast: %s", data)
+			return
+		}
+		b.Target, b.TargetValue = models.TargetDiscipline, d.Name
+		askBroadcastContent(bot, mgr, userID, chatID)
+	case data == "bc_t_class":
+		mgr.SetState(userID, states.BroadcastClass)
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите класс (например: 9А, 10Б):"))
+	case data == "bc_send":
+		if b.Kind == "" {
+			bot.Send(tgbotapi.NewMessage(chatID, "Сначала отправьте текст, фото или документ для рассылки."))
+			return
+		}
+		startBroadcast(ctx, bot, deps, mgr, userID, chatID, b)
+	default:
+		log.Printf("Unknown broadcast callback: %s", data)
+	}
+}
+
+// handleBroadcastClass принимает класс, которому адресована рассылка
+func handleBroadcastClass(bot Bot, mgr *states.Manager, userID, chatID int64, text string) {
+	b := mgr.Get(userID).Draft
+	class := strings.TrimSpace(text)
+	if b == nil || class == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите класс (например: 9А, 10Б):"))
+		return
+	}
+	b.Target, b.TargetValue = models.TargetClass, class
+	askBroadcastContent(bot, mgr, userID, chatID)
+}
+
+// handleBroadcastContent принимает текст, фото или документ и показывает превью
+func handleBroadcastContent(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, m *tgbotapi.Message) {
+	b := mgr.Get(userID).Draft
+	if b == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Черновик рассылки не найден. Начните заново с /broadcast"))
+		mgr.Reset(userID)
+		return
+	}
+
+	switch {
+	case len(m.Photo) > 0:
+		// Telegram присылает несколько размеров, последний — самый большой
+		b.Kind, b.FileID, b.Text = models.BroadcastPhoto, m.Photo[len(m.Photo)-1].FileID, m.Caption
+	case m.Document != nil:
+		b.Kind, b.FileID, b.Text = models.BroadcastDocument, m.Document.FileID, m.Caption
+	case strings.TrimSpace(m.Text) != "":
+		b.Kind, b.FileID, b.Text = models.BroadcastText, "", m.Text
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, "Поддерживаются только текст, фото и документы. Отправьте сообщение ещё раз:"))
+		return
+	}
+	mgr.Save(userID)
+
+	recipients, err := deps.Broadcasts.Recipients(ctx, b.Target, b.TargetValue)
+	if err != nil {
+		log.Printf("Error loading broadcast recipients: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке получателей. Попробуйте позже."))
+		return
+	}
+
+	// Превью — ровно то сообщение, которое получат участники
+	bot.Send(broadcastMessage(chatID, b))
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("👆 Так выглядит рассылка.\n\n🎯 Аудитория: %s\n👥 Получателей: %d\n\nОтправить?",
+		describeTarget(b), len(recipients)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Отправить", "bc_send"),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "bc_cancel"),
+		),
+	)
+	bot.Send(msg)
+	mgr.SetState(userID, states.StateIdle)
+}
+
+// askBroadcastContent просит прислать содержимое рассылки
+func askBroadcastContent(bot Bot, mgr *states.Manager, userID, chatID int64) {
+	mgr.SetState(userID, states.BroadcastContent)
+	bot.Send(tgbotapi.NewMessage(chatID, "Отправьте сообщение для рассылки: текст, фото или документ (подпись сохранится)."))
+}
+
+// cancelBroadcast удаляет черновик вместе с сессией организатора
+func cancelBroadcast(bot Bot, mgr *states.Manager, userID, chatID int64) {
+	mgr.Reset(userID)
+	bot.Send(tgbotapi.NewMessage(chatID, "Рассылка отменена."))
+}
+
+// startBroadcast сохраняет рассылку и запускает доставку в фоне
+func startBroadcast(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, b *models.Broadcast) {
+	recipients, err := deps.Broadcasts.Recipients(ctx, b.Target, b.TargetValue)
+	if err != nil {
+		log.Printf("Error loading broadcast recipients: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке получателей. Попробуйте позже."))
+		return
+	}
+	if len(recipients) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Нет ни одного получателя — рассылка не отправлена."))
+		return
+	}
+
+	ids := make([]int64, len(recipients))
+	for i, u := range recipients {
+		ids[i] = u.TelegramID
+	}
+	if err := deps.Broadcasts.Create(ctx, b, ids); err != nil {
+		log.Printf("Error saving broadcast: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении рассылки."))
+		return
+	}
+
+	mgr.Reset(userID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Рассылка #%d запущена, получателей: %d. Пришлю отчёт по завершении.", b.ID, len(recipients))))
+	log.Printf("Admin %d started broadcast %d to %d recipients", userID, b.ID, len(recipients))
+
+	deps.spawn(func() { deliverBroadcast(ctx, bot, deps, b, recipients, chatID) })
+}
+
+// deliverBroadcast отправляет рассылку всем получателям (темп задаёт sender),
+// записывает статус доставки и присылает отчёт автору и в чат организаторов
+func deliverBroadcast(ctx context.Context, bot Bot, deps *Deps, b *models.Broadcast, recipients []models.User, reportChatID int64) {
+	var sent, failed int
+	var blocked []models.User
+	for _, u := range recipients {
+		// При остановке бота недоставленные сообщения остаются в статусе pending
+		if ctx.Err() != nil {
+			log.Printf("Broadcast %d interrupted: %v", b.ID, ctx.Err())
+			break
+		}
+		status, errText := sendBroadcastTo(bot, b, u.TelegramID)
+		switch status {
+		case models.DeliverySent:
+			sent++
+		case models.DeliveryBlocked:
+			blocked = append(blocked, u)
+		default:
+			failed++
+			log.Printf("Broadcast %d to %d failed: %s", b.ID, u.TelegramID, errText)
+		}
+		if err := deps.Broadcasts.SetDeliveryStatus(ctx, b.ID, u.TelegramID, status, errText); err != nil {
+			log.Printf("Error saving delivery status of broadcast %d for %d: %v", b.ID, u.TelegramID, err)
+		}
+	}
+	if err := deps.Broadcasts.Finish(ctx, b.ID); err != nil {
+		log.Printf("Error finishing broadcast %d: %v", b.ID, err)
+	}
+
+	report := fmt.Sprintf("📣 Рассылка #%d завершена (%s)\n\n✅ Доставлено: %d\n🚫 Заблокировали бота: %d\n❌ Ошибки: %d",
+		b.ID, describeTarget(b), sent, len(blocked), failed)
+	if len(blocked) > 0 {
+		report += "\n\nЗаблокировали бота:\n"
+		for i := range blocked {
+			if i == broadcastReportLimit {
+				report += fmt.Sprintf("… и ещё %d\n", len(blocked)-i)
+				break
+			}
+			report += fmt.Sprintf("• %s\n", formatUserShort(&blocked[i]))
+		}
+	}
+
+	bot.Send(tgbotapi.NewMessage(reportChatID, report))
+	if deps.AdminChatID != reportChatID {
+		notifyAdmin(bot, deps, report)
+	}
+}
+
+// sendBroadcastTo отправляет рассылку одному участнику и возвращает статус доставки.
+// Повторы при 429 и сетевых ошибках делает sender
+func sendBroadcastTo(bot Bot, b *models.Broadcast, chatID int64) (status, errText string) {
+	_, err := bot.Send(broadcastMessage(chatID, b))
+	if err == nil {
+		return models.DeliverySent, ""
+	}
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == 403 {
+		return models.DeliveryBlocked, apiErr.Message
+	}
+	return models.DeliveryFailed, err.Error()
+}
+
+// broadcastMessage собирает сообщение рассылки для chatID
+func broadcastMessage(chatID int64, b *models.Broadcast) tgbotapi.Chattable {
+	switch b.Kind {
+	case models.BroadcastPhoto:
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(b.FileID))
+		photo.Caption = b.Text
+		return photo
+	case models.BroadcastDocument:
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileID(b.FileID))
+		doc.Caption = b.Text
+		return doc
+	default:
+		return tgbotapi.NewMessage(chatID, b.Text)
+	}
+}
+
+// describeTarget описывает аудиторию рассылки человеческим языком
+func describeTarget(b *models.Broadcast) string {
+	switch b.Target {
+	case models.TargetDiscipline:
+		return "участники " + b.TargetValue
+	case models.TargetClass:
+		return "класс " + b.TargetValue
+	default:
+		return "все участники"
+	}
+}
@@ -1,19 +1,20 @@
 package handlers
 
 import (
-    "database/sql"
+    "context"
     "fmt"
     "log"
+    "strings"
 
-    "tgbot/database"
     "tgbot/models"
+    "tgbot/registry"
     "tgbot/states"
     "tgbot/utils"
 
     tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func HandleCallback(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, update tgbotapi.Update) {
+func HandleCallback(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, update tgbotapi.Update) {
     if update.CallbackQuery == nil {
         return
     }
@@ -23,25 +24,9 @@ func HandleCallback(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, updat
     chatID := update.CallbackQuery.Message.Chat.ID
 
     switch data {
-    // Обработка выбора одиночной дисциплины
-    case "disc_bs":
-        handleDisciplineRules(bot, mgr, user.ID, chatID, "Brawl Stars", "bs", rulesBS)
-    case "disc_cr":
-        handleDisciplineRules(bot, mgr, user.ID, chatID, "Clash Royale", "cr", rulesCR)
-    case "disc_ch":
-        handleDisciplineRules(bot, mgr, user.ID, chatID, "Chess", "ch", rulesCH)
-
     // Обработка триатлона
     case "disc_tri":
-        handleTriathlonStart(bot, mgr, user.ID, chatID)
-
-    // Обработка выбора игры в триатлоне
-    case "tri_bs":
-        handleTriathlonGameSelect(bot, mgr, user.ID, chatID, "Brawl Stars")
-    case "tri_cr":
-        handleTriathlonGameSelect(bot, mgr, user.ID, chatID, "Clash Royale")
-    case "tri_ch":
-        handleTriathlonGameSelect(bot, mgr, user.ID, chatID, "Chess")
+        handleTriathlonStart(ctx, bot, deps, mgr, user.ID, chatID)
 
     // Управление триатлоном
     case "tri_check":
@@ -57,17 +42,42 @@ func HandleCallback(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, updat
 
     // Финальное подтверждение
     case "tri_confirm", "final_confirm":
-        handleConfirmRegistration(bot, db, mgr, user.ID, chatID)
+        handleConfirmRegistration(ctx, bot, deps, mgr, user.ID, chatID)
 
     // Отмена регистрации
     case "cancel_reg":
         handleCancelRegistration(bot, mgr, user.ID, chatID)
 
-    // Обработка подтверждения правил (ok_bs, ok_cr, ok_ch)
+    // Возврат к брошенной анкете по напоминанию
+    case "resume":
+        handleResume(ctx, bot, deps, mgr, user.ID, chatID)
+
+    // Выбор дисциплины (disc_<код>), игры в триатлоне (tri_<код>)
+    // и подтверждение правил (ok_<код>)
     default:
-        if len(data) > 3 && data[:3] == "ok_" {
+        if strings.HasPrefix(data, "disc_") {
+            handleDisciplineRules(ctx, bot, deps, mgr, user.ID, chatID, strings.TrimPrefix(data, "disc_"))
+        } else if strings.HasPrefix(data, "tri_") {
+            handleTriathlonGameSelect(bot, mgr, user.ID, chatID, strings.TrimPrefix(data, "tri_"))
+        } else if len(data) > 3 && data[:3] == "ok_" {
             code := data[3:]
             handleRulesOk(bot, mgr, user.ID, chatID, code)
+        } else if strings.HasPrefix(data, "vrf_") {
+            handleVerifyCallback(ctx, bot, deps, mgr, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "edit_") {
+            handleEditCallback(ctx, bot, deps, mgr, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "wd_") {
+            handleWithdrawCallback(ctx, bot, deps, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "adm_") {
+            handleAdminCallback(ctx, bot, deps, mgr, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "rep_") {
+            handleReportCallback(ctx, bot, deps, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "bc_") {
+            handleBroadcastCallback(ctx, bot, deps, mgr, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "wl_") {
+            handleWaitlistCallback(ctx, bot, deps, user.ID, chatID, data)
+        } else if strings.HasPrefix(data, "ci_") {
+            handleCheckinCallback(ctx, bot, deps, user.ID, chatID, data)
         } else {
             log.Printf("Unknown callback: %s from user %d", data, user.ID)
         }
@@ -78,33 +88,42 @@ func HandleCallback(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, updat
 }
 
 // handleDisciplineRules показывает правила выбранной дисциплины
-func handleDisciplineRules(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64, gameName, code, rules string) {
-    bot.Send(tgbotapi.NewMessage(chatID, rules))
+func handleDisciplineRules(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, code string) {
+    d, ok := registry.ByCode(code)
+    if !ok {
+        log.Printf("Unknown game code: %s", code)
+        return
+    }
+    warnIfFull(ctx, bot, deps, userID, chatID, d)
+    // Запоминаем игру, чтобы по напоминанию можно было показать правила снова
+    mgr.Get(userID).CurrentGame = d.Name
+    bot.Send(tgbotapi.NewMessage(chatID, d.Rules))
 
     m := tgbotapi.NewMessage(chatID, "Нажмите кнопку ниже, если ознакомились с правилами:")
-    m.ReplyMarkup = utils.RulesOkButton(code)
+    m.ReplyMarkup = utils.RulesOkButton(d.Code)
     bot.Send(m)
 
     mgr.SetState(userID, states.ReadingRules)
 }
 
 // handleTriathlonStart инициализирует регистрацию на триатлон
-func handleTriathlonStart(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleTriathlonStart(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64) {
+    for _, d := range registry.Triathlon() {
+        warnIfFull(ctx, bot, deps, userID, chatID, d)
+    }
     s := mgr.Get(userID)
     // Отмечаем, что это триатлон — инициализируем TriGames
     s.TriGames = make(map[string]bool)
-    s.TriGames["Brawl Stars"] = true
-    s.TriGames["Clash Royale"] = true
-    s.TriGames["Chess"] = true
+    text := "🏆 ПРАВИЛА ТРИАТЛОНА\n\n" +
+        "Вы участвуете во всех играх триатлона:\n"
+    for _, d := range registry.Triathlon() {
+        s.TriGames[d.Name] = true
+        text += "• " + d.Name + "\n"
+    }
+    text += "\nДля каждой игры необходимо ввести ник и, где требуется, тег игрока.\n\n" +
+        "Выберите игру для ввода данных:"
 
-    msg := tgbotapi.NewMessage(chatID,
-        "🏆 ПРАВИЛА ТРИАТЛОНА\n\n"+
-            "Вы участвуете во всех трёх играх:\n"+
-            "• Brawl Stars\n"+
-            "• Clash Royale\n"+
-            "• Chess (Шахматы)\n\n"+
-            "Для каждой игры необходимо ввести ник и тег игрока.\n\n"+
-            "Выберите игру для ввода данных:")
+    msg := tgbotapi.NewMessage(chatID, text)
     msg.ReplyMarkup = getTriathlonKeyboard(s.Temp.Disciplines)
     bot.Send(msg)
 
@@ -112,16 +131,21 @@ func handleTriathlonStart(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, cha
 }
 
 // handleTriathlonGameSelect переводит пользователя на ввод ника для выбранной игры
-func handleTriathlonGameSelect(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64, gameName string) {
+func handleTriathlonGameSelect(bot Bot, mgr *states.Manager, userID, chatID int64, code string) {
+    d, ok := registry.ByCode(code)
+    if !ok || !d.Triathlon {
+        log.Printf("Unknown triathlon game code: %s", code)
+        return
+    }
     s := mgr.Get(userID)
-    s.CurrentGame = gameName
+    s.CurrentGame = d.Name
     mgr.SetState(userID, states.EnteringNick)
 
-    bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", gameName)))
+    bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", d.Name)))
 }
 
 // handleTriathlonCheck показывает текущий статус заполнения
-func handleTriathlonCheck(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleTriathlonCheck(bot Bot, mgr *states.Manager, userID, chatID int64) {
     s := mgr.Get(userID)
     msg := tgbotapi.NewMessage(chatID, getTriathlonStatus(s.Temp.Disciplines))
     msg.ReplyMarkup = getTriathlonKeyboard(s.Temp.Disciplines)
@@ -129,10 +153,14 @@ func handleTriathlonCheck(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, cha
 }
 
 // handleTriathlonComplete завершает регистрацию на триатлон
-func handleTriathlonComplete(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleTriathlonComplete(bot Bot, mgr *states.Manager, userID, chatID int64) {
     s := mgr.Get(userID)
     if !isTriathlonComplete(s.Temp.Disciplines) {
-        bot.Send(tgbotapi.NewMessage(chatID, "❌ Необходимо заполнить данные для всех трёх игр!"))
+        var names []string
+        for _, d := range registry.Triathlon() {
+            names = append(names, d.Name)
+        }
+        bot.Send(tgbotapi.NewMessage(chatID, "❌ Необходимо заполнить данные для всех игр триатлона: "+strings.Join(names, ", ")+"!"))
         return
     }
 
@@ -141,7 +169,7 @@ func handleTriathlonComplete(bot *tgbotapi.BotAPI, mgr *states.Manager, userID,
 }
 
 // handleMoreDisciplines показывает оставшиеся дисциплины
-func handleMoreDisciplines(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleMoreDisciplines(bot Bot, mgr *states.Manager, userID, chatID int64) {
     s := mgr.Get(userID)
     // Очищаем флаг триатлона при выборе "Да"
     s.TriGames = nil
@@ -153,7 +181,7 @@ func handleMoreDisciplines(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, ch
 }
 
 // handleRegistrationComplete завершает регистрацию пользователя
-func handleRegistrationComplete(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleRegistrationComplete(bot Bot, mgr *states.Manager, userID, chatID int64) {
     s := mgr.Get(userID)
 
     // Показываем превью с просьбой подтвердить
@@ -161,27 +189,21 @@ func handleRegistrationComplete(bot *tgbotapi.BotAPI, mgr *states.Manager, userI
 }
 
 // handleRulesOk обрабатывает подтверждение правил
-func handleRulesOk(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64, code string) {
+func handleRulesOk(bot Bot, mgr *states.Manager, userID, chatID int64, code string) {
     s := mgr.Get(userID)
-    gameMap := map[string]string{
-        "bs": "Brawl Stars",
-        "cr": "Clash Royale",
-        "ch": "Chess",
-    }
-
-    gameName, ok := gameMap[code]
+    d, ok := registry.ByCode(code)
     if !ok {
         log.Printf("Unknown game code: %s", code)
         return
     }
 
-    s.CurrentGame = gameName
+    s.CurrentGame = d.Name
     mgr.SetState(userID, states.EnteringNick)
-    bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", gameName)))
+    bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", d.Name)))
 }
 
 // showConfirmationPreview показывает превью данных и просит подтверждение
-func showConfirmationPreview(bot *tgbotapi.BotAPI, userID, chatID int64, u *models.User, confirmCode string) {
+func showConfirmationPreview(bot Bot, userID, chatID int64, u *models.User, confirmCode string) {
     preview := fmt.Sprintf(
         "📋 ПРОВЕРКА ДАННЫХ\n\n"+
             "Пожалуйста, внимательно проверьте введённую информацию:\n\n"+
@@ -192,16 +214,12 @@ func showConfirmationPreview(bot *tgbotapi.BotAPI, userID, chatID int64, u *mode
         u.FirstName, u.LastName, u.Class)
 
     for game, gd := range u.Disciplines {
-        if game == "Chess" {
-            preview += fmt.Sprintf("  🔸 %s: %s\n", game, gd.Nick)
-        } else {
-            preview += fmt.Sprintf("  🔸 %s: %s | %s\n", game, gd.Nick, gd.Tag)
-        }
+        preview += fmt.Sprintf("  🔸 %s: %s\n", game, formatGameData(game, gd))
     }
 
     preview += "\n" +
         "✅ Я подтверждаю правильность введённой информации и согласен с её обработкой в соответствии с правилами турнира eTriathlon 2026.\n\n" +
-        "⚠️ Если вы обнаружили ошибку, удалите чат с ботом и создайте новый для корректировки данных."
+        "⚠️ Если вы обнаружили ошибку, нажмите «Отменить» и начните заново с /start. После завершения регистрации данные можно изменить командой /edit."
 
     msg := tgbotapi.NewMessage(chatID, preview)
     msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
@@ -214,22 +232,65 @@ func showConfirmationPreview(bot *tgbotapi.BotAPI, userID, chatID int64, u *mode
 }
 
 // handleConfirmRegistration обрабатывает финальное подтверждение
-func handleConfirmRegistration(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, userID, chatID int64) {
+func handleConfirmRegistration(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64) {
     s := mgr.Get(userID)
+    // Повторное нажатие или кнопка из старого превью: анкета уже сохранена или сброшена,
+    // и пустой s.Temp затёр бы настоящую регистрацию
+    if !confirmable(s) {
+        bot.Send(tgbotapi.NewMessage(chatID, "Эта кнопка уже неактуальна. Чтобы зарегистрироваться, отправьте /start"))
+        return
+    }
     s.Temp.TelegramID = userID
 
-    if err := database.SaveUser(db, s.Temp); err != nil {
+    // Регистрация могла закрыться, пока пользователь заполнял анкету
+    if registrationClosed(ctx, bot, deps, chatID) {
+        mgr.Reset(userID)
+        return
+    }
+
+    // Один тег (ник) у двух участников ломает составление пар — такую анкету не сохраняем.
+    // Проверка и запись идут под conflictsMu, чтобы между ними тег не успел занять другой участник
+    var saved *models.User
+    var waiting, freed []string
+    conflictsMu.Lock()
+    conflicts, err := findConflicts(ctx, deps, s.Temp)
+    if err == nil && len(conflicts) == 0 {
+        saved, waiting, freed, err = saveRegistration(ctx, deps, s.Temp)
+    }
+    conflictsMu.Unlock()
+    if err != nil {
         log.Printf("Error saving user: %v", err)
         bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении данных. Попробуйте позже."))
         return
     }
+    if len(conflicts) > 0 {
+        reportConflicts(bot, deps, s.Temp, chatID, conflicts)
+        return
+    }
 
-    bot.Send(tgbotapi.NewMessage(chatID, formatSummary(s.Temp)))
+    summary := formatSummary(saved)
+    if len(waiting) > 0 {
+        summary += "\n\n⏳ Лист ожидания: " + strings.Join(waiting, ", ") +
+            "\nСейчас свободных мест нет. Как только место освободится, вы получите сообщение."
+    }
+    bot.Send(tgbotapi.NewMessage(chatID, summary))
     mgr.Reset(userID)
+
+    for _, game := range freed {
+        promoteWaitlist(ctx, bot, deps, game)
+    }
+}
+
+// confirmable сообщает, ждёт ли анкета подтверждения: превью показывают
+// в выборе дисциплин или триатлона, и в анкете есть хотя бы одна игра
+func confirmable(s *states.Session) bool {
+    return s.Mode == "" &&
+        (s.State == states.ChoosingDiscipline || s.State == states.TriathlonSelect) &&
+        len(s.Temp.Disciplines) > 0
 }
 
 // handleCancelRegistration отменяет регистрацию
-func handleCancelRegistration(bot *tgbotapi.BotAPI, mgr *states.Manager, userID, chatID int64) {
+func handleCancelRegistration(bot Bot, mgr *states.Manager, userID, chatID int64) {
     mgr.Reset(userID)
     msg := tgbotapi.NewMessage(chatID,
         "❌ Регистрация отменена.\n\n"+
@@ -239,48 +300,32 @@ func handleCancelRegistration(bot *tgbotapi.BotAPI, mgr *states.Manager, userID,
 
 // formatSummary форматирует итоговое сообщение с данными пользователя
 func formatSummary(u *models.User) string {
-    summary := fmt.Sprintf("✅ Регистрация завершена!\n\n"+
-        "Ваши данные:\n"+
-        "📝 Имя: %s\n"+
+    summary := "✅ Регистрация завершена!\n\n" +
+        "Ваши данные:\n" +
+        formatUserData(u)
+
+    summary += "\nЧтобы исправить данные, используйте /edit\n" +
+        "🏆 Удачи на турнире eTriathlon 2026!"
+    return summary
+}
+
+// formatUserData форматирует анкету участника: имя, фамилия, класс и дисциплины
+func formatUserData(u *models.User) string {
+    data := fmt.Sprintf("📝 Имя: %s\n"+
         "📝 Фамилия: %s\n"+
         "📚 Класс: %s\n\n"+
         "🎮 Дисциплины:\n", u.FirstName, u.LastName, u.Class)
 
     for game, gd := range u.Disciplines {
-        if game == "Chess" {
-            summary += fmt.Sprintf("  🔸 %s: %s\n", game, gd.Nick)
-        } else {
-            summary += fmt.Sprintf("  🔸 %s: %s | %s\n", game, gd.Nick, gd.Tag)
-        }
+        data += fmt.Sprintf("  🔸 %s: %s\n", game, formatGameData(game, gd))
     }
-
-    summary += "\n🏆 Удачи на турнире eTriathlon 2026!"
-    return summary
+    return data
 }
 
-// Константы с правилами дисциплин
-const (
-    rulesBS = "📋 ПРАВИЛА BRAWL STARS:\n" +
-        "Формат: 1v1 (Дружеский бой)\n" +
-        "Один из игроков создаёт код команды и приглашает другого.\n" +
-        "Второй присоединяется по коду или через приглашение в друзья.\n" +
-        "Один из участников создаёт пустую карту в режиме \"Награда за поимку\".\n" +
-        "Игроки по очереди выбирают персонажей.\n" +
-        "Победителем считается тот, кто выиграл 2 матча.\n" +
-        "При счёте 1:1 выбирают персонажа, предложенного судьями."
-
-    rulesCR = "📋 ПРАВИЛА CLASH ROYALE:\n" +
-        "Формат: 1v1 (Дружеский бой)\n" +
-        "Один из игроков отправляет запрос «Дружеский бой».\n" +
-        "Оба игрока должны добавить друг друга в друзья.\n" +
-        "Матч проводится до одной победы/ничьи на групповом этапе.\n" +
-        "В плей-офф — до одной победы."
-
-    rulesCH = "📋 ПРАВИЛА ШАХМАТ:\n" +
-        "Платформа: Chess.com\n" +
-        "Контроль времени: 10+3 минуты\n" +
-        "Создатель матча выставляет параметры.\n" +
-        "Второй игрок получает приглашение или ссылку.\n" +
-        "Матч на групповом этапе до одной победы/ничьи.\n" +
-        "В плей-офф — до одной победы."
-)
\ No newline at end of file
+// formatGameData форматирует данные игрока в дисциплине: «ник» или «ник | тег»
+func formatGameData(game string, gd models.GameData) string {
+    if d, ok := registry.ByName(game); (ok && !d.NeedsTag()) || gd.Tag == "" {
+        return gd.Nick
+    }
+    return fmt.Sprintf("%s | %s", gd.Nick, gd.Tag)
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/registry"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// checkinRemindEvery — интервал напоминаний о чек-ине, если организатор не указал свой
+const checkinRemindEvery = 30 * time.Minute
+
+// HandleCheckin управляет чек-ином перед турниром:
+// /checkin — состояние, /checkin open <код|all> <2h> [напоминать каждые 30m],
+// /checkin close <код|all>, /checkin reset <код|all>
+func HandleCheckin(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		sendCheckinStatus(ctx, bot, deps, chatID)
+		return
+	}
+
+	usage := "Использование:\n" +
+		"/checkin open <" + disciplineCodes() + "|all> <длительность, например 2h> [интервал напоминаний, например 30m или 0]\n" +
+		"/checkin close <код|all>\n" +
+		"/checkin reset <код|all> — забыть отметки, сетка снова строится из всех участников"
+	if len(args) < 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	games, ok := checkinTargets(args[1])
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+
+	switch {
+	case args[0] == "open" && (len(args) == 3 || len(args) == 4):
+		duration, err := time.ParseDuration(args[2])
+		if err != nil || duration <= 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверная длительность. Примеры: 90m, 2h"))
+			return
+		}
+		remind := checkinRemindEvery
+		if len(args) == 4 {
+			if remind, err = time.ParseDuration(args[3]); err != nil || remind < 0 {
+				bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверный интервал напоминаний. Примеры: 30m, 1h, 0"))
+				return
+			}
+		}
+		openCheckin(ctx, bot, deps, chatID, games, time.Now().Add(duration), remind)
+	case args[0] == "close" && len(args) == 2:
+		for _, d := range games {
+			err := deps.Checkins.Close(ctx, d.Name)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error closing check-in of %s: %v", d.Name, err)
+				bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+				return
+			}
+		}
+		sendCheckinStatus(ctx, bot, deps, chatID)
+	case args[0] == "reset" && len(args) == 2:
+		for _, d := range games {
+			if err := deps.Checkins.Reset(ctx, d.Name); err != nil {
+				log.Printf("Error resetting check-in of %s: %v", d.Name, err)
+				bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+				return
+			}
+		}
+		sendCheckinStatus(ctx, bot, deps, chatID)
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+	}
+}
+
+// checkinTargets разбирает код дисциплины или all
+func checkinTargets(arg string) ([]registry.Discipline, bool) {
+	if arg == "all" {
+		return registry.All(), true
+	}
+	d, ok := registry.ByCode(arg)
+	return []registry.Discipline{d}, ok
+}
+
+// openCheckin открывает окно чек-ина и рассылает участникам кнопки «Я здесь»
+func openCheckin(ctx context.Context, bot Bot, deps *Deps, chatID int64, games []registry.Discipline, closes time.Time, remind time.Duration) {
+	invites := make(map[int64][]registry.Discipline)
+	for _, d := range games {
+		if err := deps.Checkins.Open(ctx, d.Name, closes, remind); err != nil {
+			log.Printf("Error opening check-in of %s: %v", d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+			return
+		}
+		pending, err := pendingCheckins(ctx, deps, d.Name)
+		if err != nil {
+			log.Printf("Error loading check-ins of %s: %v", d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участников."))
+			return
+		}
+		for _, u := range pending {
+			invites[u.TelegramID] = append(invites[u.TelegramID], d)
+		}
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Чек-ин открыт до %s. Приглашения отправляются %d участникам.",
+		deps.formatTime(closes, deadlineLayout), len(invites))))
+
+	text := fmt.Sprintf("📍 Начался чек-ин! Подтвердите до %s, что будете играть, — без отметки вы не попадёте в турнирную сетку.",
+		deps.formatTime(closes, deadlineLayout))
+	deps.spawn(func() { sendCheckinInvites(ctx, bot, invites, text) })
+}
+
+// sendCheckinInvites отправляет каждому участнику одно сообщение с кнопками его дисциплин
+func sendCheckinInvites(ctx context.Context, bot Bot, invites map[int64][]registry.Discipline, text string) {
+	for tgID, games := range invites {
+		if ctx.Err() != nil {
+			log.Printf("Check-in invites interrupted: %v", ctx.Err())
+			return
+		}
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, d := range games {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Я здесь — "+d.Name, "ci_"+d.Code),
+			))
+		}
+		msg := tgbotapi.NewMessage(tgID, text)
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		bot.Send(msg)
+	}
+}
+
+// pendingCheckins возвращает активных участников дисциплины, которые ещё не отметились
+func pendingCheckins(ctx context.Context, deps *Deps, game string) ([]models.User, error) {
+	users, _, err := deps.Users.List(ctx, database.UserFilter{Discipline: game, Status: models.UserActive}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	checked, err := deps.Checkins.CheckedIn(ctx, game)
+	if err != nil {
+		return nil, err
+	}
+	var pending []models.User
+	for _, u := range users {
+		if !checked[u.TelegramID] {
+			pending = append(pending, u)
+		}
+	}
+	return pending, nil
+}
+
+// handleCheckinCallback отмечает участника по кнопке «Я здесь»: ci_<код>
+func handleCheckinCallback(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, data string) {
+	d, ok := registry.ByCode(strings.TrimPrefix(data, "ci_"))
+	if !ok {
+		log.Printf("Unknown check-in callback: %s from user %d", data, userID)
+		return
+	}
+
+	w, err := deps.Checkins.Window(ctx, d.Name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error loading check-in window of %s: %v", d.Name, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+	if err != nil || !w.Open(time.Now()) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Чек-ин в %s сейчас закрыт.", d.Name)))
+		return
+	}
+
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error loading user %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Участника могли удалить уже после приглашения
+		u = &models.User{}
+	}
+	if _, registered := u.Disciplines[d.Name]; !registered {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Вы не зарегистрированы в %s.", d.Name)))
+		return
+	}
+
+	if err := deps.Checkins.CheckIn(ctx, d.Name, userID); err != nil {
+		log.Printf("Error checking in %d to %s: %v", userID, d.Name, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении. Попробуйте позже."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Отметка принята: вы играете в %s. До встречи на турнире!", d.Name)))
+}
+
+// SendCheckinReminders напоминает о чек-ине тем, кто ещё не отметился,
+// если с прошлого напоминания прошёл интервал окна
+func SendCheckinReminders(ctx context.Context, bot Bot, deps *Deps) {
+	windows, err := deps.Checkins.Windows(ctx)
+	if err != nil {
+		log.Printf("Error loading check-in windows: %v", err)
+		return
+	}
+
+	now := time.Now()
+	reminders := make(map[int64][]registry.Discipline)
+	var closes time.Time
+	for _, w := range windows {
+		d, ok := registry.ByName(w.Discipline)
+		if !ok || !w.Open(now) || w.RemindEvery <= 0 || now.Sub(w.RemindedAt) < w.RemindEvery {
+			continue
+		}
+		if err := deps.Checkins.MarkReminded(ctx, w.Discipline, now); err != nil {
+			log.Printf("Error saving check-in reminder of %s: %v", w.Discipline, err)
+			continue
+		}
+		pending, err := pendingCheckins(ctx, deps, w.Discipline)
+		if err != nil {
+			log.Printf("Error loading check-ins of %s: %v", w.Discipline, err)
+			continue
+		}
+		for _, u := range pending {
+			reminders[u.TelegramID] = append(reminders[u.TelegramID], d)
+		}
+		if closes.IsZero() || w.ClosesAt.Before(closes) {
+			closes = w.ClosesAt
+		}
+	}
+	if len(reminders) == 0 {
+		return
+	}
+
+	log.Printf("Sending check-in reminders to %d users", len(reminders))
+	sendCheckinInvites(ctx, bot, reminders, fmt.Sprintf(
+		"⏰ Напоминание: вы ещё не отметились на чек-ине. Чек-ин закрывается %s — без отметки вы не попадёте в сетку.",
+		deps.formatTime(closes, deadlineLayout)))
+}
+
+// sendCheckinStatus показывает окна чек-ина и сколько участников отметилось
+func sendCheckinStatus(ctx context.Context, bot Bot, deps *Deps, chatID int64) {
+	counts, err := deps.Users.CountByDiscipline(ctx)
+	if err != nil {
+		log.Printf("Error counting users: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке статистики."))
+		return
+	}
+
+	now := time.Now()
+	text := "📍 ЧЕК-ИН\n\n"
+	for _, d := range registry.All() {
+		w, err := deps.Checkins.Window(ctx, d.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			text += fmt.Sprintf("• %s: не проводился, в сетку попадут все %d участников\n", d.Name, counts[d.Name])
+			continue
+		}
+		if err != nil {
+			log.Printf("Error loading check-in window of %s: %v", d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных."))
+			return
+		}
+		checked, err := deps.Checkins.CheckedIn(ctx, d.Name)
+		if err != nil {
+			log.Printf("Error loading check-ins of %s: %v", d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных."))
+			return
+		}
+		state := "закрыт " + deps.formatTime(w.ClosesAt, deadlineLayout)
+		if w.Open(now) {
+			state = "открыт до " + deps.formatTime(w.ClosesAt, deadlineLayout)
+		}
+		text += fmt.Sprintf("• %s: %s, отметились %d из %d\n", d.Name, state, len(checked), counts[d.Name])
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// checkedInOnly оставляет в списке только отметившихся на чек-ине, если он проводился
+func checkedInOnly(ctx context.Context, deps *Deps, game string, users []models.User) ([]models.User, bool, error) {
+	_, err := deps.Checkins.Window(ctx, game)
+	if errors.Is(err, sql.ErrNoRows) {
+		return users, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	checked, err := deps.Checkins.CheckedIn(ctx, game)
+	if err != nil {
+		return nil, false, err
+	}
+	var kept []models.User
+	for _, u := range users {
+		if checked[u.TelegramID] {
+			kept = append(kept, u)
+		}
+	}
+	return kept, true, nil
+}
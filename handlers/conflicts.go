@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/registry"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// conflictsMu не даёт двум участникам одновременно сохранить один тег (ник):
+// проверка совпадений и запись анкеты идут под ним
+var conflictsMu sync.Mutex
+
+// findConflicts ищет других активных участников с тем же тегом (или ником, если
+// тега в игре нет) в тех же дисциплинах, что и анкета u
+func findConflicts(ctx context.Context, deps *Deps, u *models.User) ([]models.Conflict, error) {
+	var games []string
+	for game := range u.Disciplines {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+
+	var conflicts []models.Conflict
+	for _, game := range games {
+		field, value := idValue(game, u.Disciplines[game])
+		if value == "" {
+			continue
+		}
+		holders, err := deps.Users.FindByGameField(ctx, game, field, value)
+		if err != nil {
+			return nil, err
+		}
+		ids := []int64{u.TelegramID}
+		for _, h := range holders {
+			if h.TelegramID != u.TelegramID {
+				ids = append(ids, h.TelegramID)
+			}
+		}
+		if len(ids) > 1 {
+			conflicts = append(conflicts, models.Conflict{Discipline: game, Field: field, Value: value, TelegramIDs: ids})
+		}
+	}
+	return conflicts, nil
+}
+
+// idValue возвращает поле, по которому игрок опознаётся в игре, и его значение
+func idValue(game string, gd models.GameData) (string, string) {
+	field := registry.FieldNick
+	if d, ok := registry.ByName(game); ok {
+		field = d.IDField()
+	}
+	if field == registry.FieldTag {
+		return field, gd.Tag
+	}
+	return field, gd.Nick
+}
+
+// reportConflicts объясняет пользователю, почему анкета не сохранена, и предупреждает организаторов
+func reportConflicts(bot Bot, deps *Deps, u *models.User, chatID int64, conflicts []models.Conflict) {
+	text := "❌ Регистрация не завершена: эти данные уже указаны другим участником.\n\n"
+	for _, c := range conflicts {
+		text += fmt.Sprintf("  🔸 %s: %s %s\n", c.Discipline, fieldName(c.Field), c.Value)
+	}
+	text += "\nПроверьте ник и тег, нажмите «Отменить» и пройдите регистрацию заново с /start. " +
+		"Если это ваш аккаунт, напишите организаторам — они уже получили уведомление."
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+	notifyAdmin(bot, deps, conflictAlert(u, "пытается зарегистрироваться с занятыми данными", conflicts))
+}
+
+// reportEditConflicts объясняет пользователю, почему изменение не сохранено, и предупреждает организаторов
+func reportEditConflicts(bot Bot, deps *Deps, u *models.User, chatID int64, conflicts []models.Conflict) {
+	text := "❌ Изменение не сохранено: эти данные уже указаны другим участником.\n\n"
+	for _, c := range conflicts {
+		text += fmt.Sprintf("  🔸 %s: %s %s\n", c.Discipline, fieldName(c.Field), c.Value)
+	}
+	text += "\nВведите другое значение. Если это ваш аккаунт, напишите организаторам — они уже получили уведомление."
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+	notifyAdmin(bot, deps, conflictAlert(u, "пытается указать в анкете занятые данные", conflicts))
+}
+
+// conflictAlert — уведомление организаторам о совпадениях в анкете участника
+func conflictAlert(u *models.User, action string, conflicts []models.Conflict) string {
+	alert := fmt.Sprintf("⚠️ %s %s (%d) %s:\n", u.FirstName, u.LastName, u.TelegramID, action)
+	for _, c := range conflicts {
+		alert += fmt.Sprintf("• %s, %s %s — уже у %s\n", c.Discipline, fieldName(c.Field), c.Value, formatIDs(c.TelegramIDs[1:]))
+	}
+	return alert
+}
+
+// HandleConflicts ищет по всей таблице участников с одинаковыми тегами и никами: /conflicts
+func HandleConflicts(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	all, err := deps.Users.Conflicts(ctx)
+	if err != nil {
+		log.Printf("Error scanning conflicts: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при поиске совпадений."))
+		return
+	}
+
+	// Одинаковые ники в играх с тегом — не конфликт: в таких играх игрока опознают по тегу
+	var conflicts []models.Conflict
+	for _, c := range all {
+		if d, ok := registry.ByName(c.Discipline); !ok || d.IDField() == c.Field {
+			conflicts = append(conflicts, c)
+		}
+	}
+	if len(conflicts) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "✅ Совпадающих тегов и ников нет."))
+		return
+	}
+
+	users, _, err := deps.Users.List(ctx, database.UserFilter{Status: models.UserActive}, 0, 0)
+	if err != nil {
+		log.Printf("Error loading users: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участников."))
+		return
+	}
+	byID := usersByTelegramID(users)
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "⚠️ СОВПАДЕНИЯ (%d)\n\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Fprintf(&text, "🎮 %s, %s %s:\n", c.Discipline, fieldName(c.Field), c.Value)
+		for _, id := range c.TelegramIDs {
+			u := byID[id]
+			fmt.Fprintf(&text, "  • %s %s, %s (%d)\n", u.FirstName, u.LastName, u.Class, id)
+		}
+		text.WriteString("\n")
+	}
+	sendLong(bot, chatID, text.String())
+}
+
+func fieldName(field string) string {
+	if field == registry.FieldTag {
+		return "тег"
+	}
+	return "ник"
+}
+
+func formatIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+	return strings.Join(s, ", ")
+}
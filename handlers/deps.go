@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/profiles"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Bot — то, что обработчикам нужно от Telegram: отправка сообщений и запросов.
+// В проде это sender.Sender, в сценарных проверках — handlertest.FakeBot
+type Bot interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// UserRepository — хранилище анкет участников (database.UserRepository или handlertest.MemoryUsers)
+type UserRepository interface {
+	Save(ctx context.Context, u *models.User) error
+	GetByTelegramID(ctx context.Context, tgID int64) (*models.User, error)                          // sql.ErrNoRows, если нет
+	GetByID(ctx context.Context, id int64) (*models.User, error)                                    // sql.ErrNoRows, если нет
+	List(ctx context.Context, f database.UserFilter, limit, offset int) ([]models.User, int, error) // limit <= 0 — все
+	CountByDiscipline(ctx context.Context) (map[string]int, error)
+	FindByGameField(ctx context.Context, game, field, value string) ([]models.User, error) // без учёта регистра
+	Conflicts(ctx context.Context) ([]models.Conflict, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error)
+	Delete(ctx context.Context, id int64) error
+	AddDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error // sql.ErrNoRows, если нет
+	SetDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error // sql.ErrNoRows, если не играет
+	SetProfileField(ctx context.Context, tgID int64, field, value string) error           // first_name, last_name или class
+	WithdrawDiscipline(ctx context.Context, tgID int64, game string) error
+	Withdraw(ctx context.Context, tgID int64) error
+	Stats(ctx context.Context, triathlon []string) (*database.RegistrationStats, error)
+}
+
+// Settings — настройки турнира, которые задают организаторы (database.SettingsStore или handlertest.MemorySettings)
+type Settings interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Waitlist — очереди ожидания в заполненные дисциплины (database.WaitlistRepository или handlertest.MemoryWaitlist)
+type Waitlist interface {
+	Add(ctx context.Context, game string, tgID int64, gd models.GameData) error      // в конец очереди
+	Get(ctx context.Context, game string, tgID int64) (*models.WaitlistEntry, error) // sql.ErrNoRows, если не ждёт
+	List(ctx context.Context, game string) ([]models.WaitlistEntry, error)           // по порядку очереди
+	ByUser(ctx context.Context, tgID int64) ([]models.WaitlistEntry, error)
+	Expired(ctx context.Context, now time.Time) ([]models.WaitlistEntry, error)
+	Offer(ctx context.Context, game string, tgID int64, expires time.Time) error
+	Move(ctx context.Context, game string, tgID int64, position int) error // sql.ErrNoRows, если не ждёт
+	Remove(ctx context.Context, game string, tgID int64) error
+	RemoveUser(ctx context.Context, tgID int64) error
+}
+
+// Jobs — постоянная очередь отложенных задач (database.JobRepository или handlertest.MemoryJobs)
+type Jobs interface {
+	Put(ctx context.Context, job models.Job) error // заменяет задачу с тем же ключом
+	Cancel(ctx context.Context, key string) error
+}
+
+// Matches — турнирные сетки (database.MatchRepository или handlertest.MemoryMatches)
+type Matches interface {
+	Replace(ctx context.Context, discipline string, matches []models.Match) error // заполняет ID матчей
+	List(ctx context.Context, discipline string) ([]models.Match, error)          // группы, затем раунды плей-офф
+	Get(ctx context.Context, id int64) (*models.Match, error)                     // sql.ErrNoRows, если нет
+	Update(ctx context.Context, matches ...models.Match) error
+	RecordResult(ctx context.Context, done models.Match, changed ...models.Match) error // sql.ErrNoRows, если результат уже засчитан
+	SetStart(ctx context.Context, id int64, startsAt time.Time) error                   // sql.ErrNoRows, если нет
+	ByPlayer(ctx context.Context, tgID int64) ([]models.Match, error)                   // только матчи с известным соперником
+}
+
+// Admins — роли организаторов (database.AdminRepository или handlertest.MemoryAdmins)
+type Admins interface {
+	Role(ctx context.Context, tgID int64) (string, error) // sql.ErrNoRows, если не организатор
+	SetRole(ctx context.Context, tgID int64, role string, addedBy int64) error
+	Delete(ctx context.Context, tgID int64) error // sql.ErrNoRows, если не организатор
+	CountOwners(ctx context.Context) (int, error)
+	List(ctx context.Context) ([]database.AdminRecord, error)
+}
+
+// Broadcasts — рассылки и статусы их доставки (database.BroadcastRepository или handlertest.MemoryBroadcasts)
+type Broadcasts interface {
+	Recipients(ctx context.Context, target, value string) ([]models.User, error) // активные участники
+	Create(ctx context.Context, b *models.Broadcast, recipients []int64) error   // заполняет ID
+	SetDeliveryStatus(ctx context.Context, broadcastID, tgID int64, status, errText string) error
+	Finish(ctx context.Context, broadcastID int64) error
+}
+
+// Checkins — окна чек-ина и отметки участников (database.CheckinRepository или handlertest.MemoryCheckins)
+type Checkins interface {
+	Open(ctx context.Context, game string, closes time.Time, remindEvery time.Duration) error
+	Close(ctx context.Context, game string) error // sql.ErrNoRows, если окно не открыто
+	Reset(ctx context.Context, game string) error
+	Window(ctx context.Context, game string) (*models.CheckinWindow, error) // sql.ErrNoRows, если чек-ина не было
+	Windows(ctx context.Context) ([]models.CheckinWindow, error)
+	MarkReminded(ctx context.Context, game string, at time.Time) error
+	CheckIn(ctx context.Context, game string, tgID int64) error
+	CheckedIn(ctx context.Context, game string) (map[int64]bool, error)
+}
+
+// Deps — хранилища и настройки, с которыми работают обработчики. В проде их
+// собирает main из Postgres, в сценарных проверках — handlertest из памяти
+type Deps struct {
+	Users      UserRepository
+	Settings   Settings
+	Waitlist   Waitlist
+	Jobs       Jobs
+	Matches    Matches
+	Admins     Admins
+	Broadcasts Broadcasts
+	Checkins   Checkins
+
+	Verifiers   map[string]profiles.Verifier // проверка аккаунтов по платформе из поля verify реестра дисциплин
+	AdminChatID int64                        // чат организаторов для уведомлений; 0 — не уведомлять
+	Owners      []int64                      // владельцы из ADMIN_IDS: их роль задаёт конфигурация, а не /grant и /revoke
+
+	// Location — часовой пояс турнира: в нём организаторы вводят даты, а участники
+	// их видят; nil — пояс сервера
+	Location *time.Location
+
+	SessionRemindAfter time.Duration // через сколько без ответа напомнить о незаконченной анкете
+	SessionTTL         time.Duration // через сколько без ответа удалить незаконченную анкету
+
+	// Background отслеживает рассылки и проверки аккаунтов, которые обработчики
+	// запускают в фоне, чтобы при остановке main дождался их; nil — не отслеживать
+	Background *sync.WaitGroup
+}
+
+// configuredOwner сообщает, задан ли владелец в ADMIN_IDS
+func (d *Deps) configuredOwner(tgID int64) bool {
+	for _, id := range d.Owners {
+		if id == tgID {
+			return true
+		}
+	}
+	return false
+}
+
+// location — часовой пояс турнира
+func (d *Deps) location() *time.Location {
+	if d.Location == nil {
+		return time.Local
+	}
+	return d.Location
+}
+
+// parseTime разбирает дату, введённую организатором в формате deadlineLayout,
+// в часовом поясе турнира
+func (d *Deps) parseTime(value string) (time.Time, error) {
+	return time.ParseInLocation(deadlineLayout, value, d.location())
+}
+
+// formatTime выводит момент t в часовом поясе турнира
+func (d *Deps) formatTime(t time.Time, layout string) string {
+	return t.In(d.location()).Format(layout)
+}
+
+// spawn запускает f в фоне, учитывая её в Background
+func (d *Deps) spawn(f func()) {
+	if d.Background == nil {
+		go f()
+		return
+	}
+	d.Background.Add(1)
+	go func() {
+		defer d.Background.Done()
+		f()
+	}()
+}
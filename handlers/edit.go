@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tgbot/models"
+	"tgbot/registry"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// settingEditDeadline — ключ настройки с крайним сроком изменения анкет (RFC3339)
+const settingEditDeadline = "edit_deadline"
+
+// deadlineLayout — формат даты в командах админа: /deadline 01.03.2026 18:00
+const deadlineLayout = "02.01.2006 15:04"
+
+// HandleEdit открывает меню редактирования сохранённой анкеты: /edit
+func HandleEdit(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	if editLocked(ctx, bot, deps, chatID) {
+		return
+	}
+
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы ещё не зарегистрированы. Используйте /start для регистрации."))
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading user %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+	if u.Status == models.UserWithdrawn {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы сняты с турнира. Чтобы зарегистрироваться снова, используйте /start"))
+		return
+	}
+	if u.Disciplines == nil {
+		u.Disciplines = make(map[string]models.GameData)
+	}
+
+	mgr.Reset(userID)
+	s := mgr.Get(userID)
+	s.Temp = u
+	s.Mode = states.ModeEdit
+	mgr.SetState(userID, states.StateIdle)
+
+	showEditMenu(bot, chatID, u)
+}
+
+// HandleDeadline задаёт крайний срок изменения анкет: /deadline 01.03.2026 18:00, /deadline off
+func HandleDeadline(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+
+	switch arg {
+	case "":
+		deadline, ok := editDeadline(ctx, deps)
+		if !ok {
+			bot.Send(tgbotapi.NewMessage(chatID, "Срок изменения анкет не ограничен.\nЧтобы задать: /deadline 01.03.2026 18:00"))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Анкеты можно изменять до %s.", deps.formatTime(deadline, deadlineLayout))))
+	case "off":
+		if err := deps.Settings.Delete(ctx, settingEditDeadline); err != nil {
+			log.Printf("Error deleting deadline: %v", err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении настройки."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "✅ Ограничение срока изменения анкет снято."))
+	default:
+		deadline, err := deps.parseTime(arg)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверный формат даты. Пример: /deadline 01.03.2026 18:00"))
+			return
+		}
+		if err := deps.Settings.Set(ctx, settingEditDeadline, deadline.Format(time.RFC3339)); err != nil {
+			log.Printf("Error saving deadline: %v", err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении настройки."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Анкеты можно изменять до %s.", deps.formatTime(deadline, deadlineLayout))))
+	}
+}
+
+// handleEditCallback обрабатывает кнопки меню редактирования:
+// edit_first, edit_last, edit_class, edit_d_<код игры>, edit_done
+func handleEditCallback(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, data string) {
+	s := mgr.Get(userID)
+	if s.Mode != states.ModeEdit {
+		bot.Send(tgbotapi.NewMessage(chatID, "Меню устарело. Откройте его заново командой /edit"))
+		return
+	}
+	if data == "edit_done" {
+		mgr.Reset(userID)
+		// В сессии может остаться отклонённое значение — показываем сохранённую анкету
+		u := s.Temp
+		if saved, err := deps.Users.GetByTelegramID(ctx, userID); err == nil {
+			u = saved
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "✅ Редактирование завершено.\n\n"+formatUserData(u)))
+		return
+	}
+	if editLocked(ctx, bot, deps, chatID) {
+		mgr.Reset(userID)
+		return
+	}
+
+	switch data {
+	case "edit_first":
+		mgr.SetState(userID, states.WaitingName)
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новое имя:"))
+	case "edit_last":
+		mgr.SetState(userID, states.WaitingLastName)
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новую фамилию:"))
+	case "edit_class":
+		mgr.SetState(userID, states.WaitingClass)
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новый класс (например: 9А, 10Б):"))
+	default:
+		d, ok := registry.ByCode(strings.TrimPrefix(data, "edit_d_"))
+		if _, registered := s.Temp.Disciplines[d.Name]; !ok || !registered {
+			log.Printf("Unknown edit callback: %s from user %d", data, userID)
+			return
+		}
+		s.CurrentGame = d.Name
+		mgr.SetState(userID, states.EnteringNick)
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", d.Name)))
+	}
+}
+
+// saveEdit сохраняет изменённое поле анкеты и снова показывает меню редактирования.
+// Записывается только поле текущего шага: остальная анкета могла измениться,
+// пока пользователь вводил ответ (например, он снялся с дисциплины)
+func saveEdit(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64) {
+	s := mgr.Get(userID)
+	if editLocked(ctx, bot, deps, chatID) {
+		mgr.Reset(userID)
+		return
+	}
+
+	var err error
+	switch s.State {
+	case states.WaitingName:
+		err = deps.Users.SetProfileField(ctx, userID, "first_name", s.Temp.FirstName)
+	case states.WaitingLastName:
+		err = deps.Users.SetProfileField(ctx, userID, "last_name", s.Temp.LastName)
+	case states.WaitingClass:
+		err = deps.Users.SetProfileField(ctx, userID, "class", s.Temp.Class)
+	default:
+		var conflicts []models.Conflict
+		conflicts, err = saveEditedGame(ctx, deps, s.Temp, s.CurrentGame)
+		if err == nil && len(conflicts) > 0 {
+			// Остаёмся на том же шаге: пользователь введёт другое значение
+			reportEditConflicts(bot, deps, s.Temp, chatID, conflicts)
+			return
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		mgr.Reset(userID)
+		bot.Send(tgbotapi.NewMessage(chatID, "Анкета изменилась, пока вы её редактировали. Откройте её заново командой /edit"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving user: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении данных. Попробуйте позже."))
+		return
+	}
+
+	// Показываем анкету такой, какая она сейчас в базе
+	if u, err := deps.Users.GetByTelegramID(ctx, userID); err == nil {
+		s.Temp = u
+	} else {
+		log.Printf("Error loading user %d: %v", userID, err)
+	}
+	s.CurrentGame = ""
+	mgr.SetState(userID, states.StateIdle)
+	bot.Send(tgbotapi.NewMessage(chatID, "✅ Данные обновлены!"))
+	showEditMenu(bot, chatID, s.Temp)
+}
+
+// saveEditedGame сохраняет данные дисциплины game из анкеты u, если её тег (ник)
+// не указан другим участником; иначе возвращает совпадения
+func saveEditedGame(ctx context.Context, deps *Deps, u *models.User, game string) ([]models.Conflict, error) {
+	gd := u.Disciplines[game]
+	conflictsMu.Lock()
+	defer conflictsMu.Unlock()
+
+	only := &models.User{TelegramID: u.TelegramID, Disciplines: map[string]models.GameData{game: gd}}
+	conflicts, err := findConflicts(ctx, deps, only)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+	return nil, deps.Users.SetDiscipline(ctx, u.TelegramID, game, gd)
+}
+
+// showEditMenu показывает текущие данные и кнопки выбора поля для изменения
+func showEditMenu(bot Bot, chatID int64, u *models.User) {
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📝 Имя", "edit_first"),
+			tgbotapi.NewInlineKeyboardButtonData("📝 Фамилия", "edit_last"),
+			tgbotapi.NewInlineKeyboardButtonData("📚 Класс", "edit_class"),
+		),
+	}
+	for _, d := range registry.All() {
+		if _, ok := u.Disciplines[d.Name]; ok {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🎮 "+d.Name, "edit_d_"+d.Code),
+			))
+		}
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Готово", "edit_done"),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, "✏️ РЕДАКТИРОВАНИЕ АНКЕТЫ\n\n"+formatUserData(u)+"\nЧто хотите изменить?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+// editLocked сообщает пользователю, если срок изменения анкет истёк
+func editLocked(ctx context.Context, bot Bot, deps *Deps, chatID int64) bool {
+	deadline, ok := editDeadline(ctx, deps)
+	if !ok || time.Now().Before(deadline) {
+		return false
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"🔒 Изменение анкет закрыто %s. Если нужно исправить данные, обратитесь к организаторам.",
+		deps.formatTime(deadline, deadlineLayout))))
+	return true
+}
+
+// editDeadline возвращает крайний срок изменения анкет, если он задан
+func editDeadline(ctx context.Context, deps *Deps) (time.Time, bool) {
+	return timeSetting(ctx, deps, settingEditDeadline)
+}
+
+// timeSetting читает настройку с моментом времени в формате RFC3339
+func timeSetting(ctx context.Context, deps *Deps, key string) (time.Time, bool) {
+	value, ok, err := deps.Settings.Get(ctx, key)
+	if err != nil {
+		log.Printf("Error loading %s: %v", key, err)
+		return time.Time{}, false
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Printf("Bad %s value %q: %v", key, value, err)
+		return time.Time{}, false
+	}
+	return t, true
+}
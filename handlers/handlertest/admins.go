@@ -0,0 +1,142 @@
+package handlertest
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tgbot/database"
+	"tgbot/models"
+)
+
+// MemoryAdmins implements handlers.Admins on a map by Telegram ID
+type MemoryAdmins struct {
+	mu     sync.Mutex
+	admins map[int64]database.AdminRecord
+}
+
+func NewMemoryAdmins() *MemoryAdmins {
+	return &MemoryAdmins{admins: make(map[int64]database.AdminRecord)}
+}
+
+func (a *MemoryAdmins) Role(ctx context.Context, tgID int64) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.admins[tgID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return rec.Role, nil
+}
+
+func (a *MemoryAdmins) SetRole(ctx context.Context, tgID int64, role string, addedBy int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec, ok := a.admins[tgID]
+	if !ok {
+		rec = database.AdminRecord{TelegramID: tgID, CreatedAt: time.Now()}
+	}
+	rec.Role, rec.AddedBy = role, addedBy
+	a.admins[tgID] = rec
+	return nil
+}
+
+func (a *MemoryAdmins) Delete(ctx context.Context, tgID int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.admins[tgID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(a.admins, tgID)
+	return nil
+}
+
+func (a *MemoryAdmins) CountOwners(ctx context.Context) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, rec := range a.admins {
+		if rec.Role == "owner" {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (a *MemoryAdmins) List(ctx context.Context) ([]database.AdminRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []database.AdminRecord
+	for _, rec := range a.admins {
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// MemoryBroadcasts implements handlers.Broadcasts: recipients come from the
+// users store, deliveries are recorded by broadcast and recipient
+type MemoryBroadcasts struct {
+	users *MemoryUsers
+
+	mu         sync.Mutex
+	broadcasts []models.Broadcast
+	deliveries map[int64]map[int64]string
+	finished   map[int64]bool
+}
+
+func NewMemoryBroadcasts(users *MemoryUsers) *MemoryBroadcasts {
+	return &MemoryBroadcasts{
+		users:      users,
+		deliveries: make(map[int64]map[int64]string),
+		finished:   make(map[int64]bool),
+	}
+}
+
+func (b *MemoryBroadcasts) Recipients(ctx context.Context, target, value string) ([]models.User, error) {
+	return b.users.filter(func(u *models.User) bool {
+		if u.Status != models.UserActive {
+			return false
+		}
+		switch target {
+		case models.TargetDiscipline:
+			_, ok := u.Disciplines[value]
+			return ok
+		case models.TargetClass:
+			return strings.EqualFold(u.Class, value)
+		}
+		return true
+	}), nil
+}
+
+func (b *MemoryBroadcasts) Create(ctx context.Context, br *models.Broadcast, recipients []int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br.ID = int64(len(b.broadcasts) + 1)
+	br.CreatedAt = time.Now()
+	b.broadcasts = append(b.broadcasts, *br)
+	b.deliveries[br.ID] = make(map[int64]string, len(recipients))
+	for _, id := range recipients {
+		b.deliveries[br.ID][id] = models.DeliveryPending
+	}
+	return nil
+}
+
+func (b *MemoryBroadcasts) SetDeliveryStatus(ctx context.Context, broadcastID, tgID int64, status, errText string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d, ok := b.deliveries[broadcastID]; ok {
+		d[tgID] = status
+	}
+	return nil
+}
+
+func (b *MemoryBroadcasts) Finish(ctx context.Context, broadcastID int64) error {
+	b.mu.Lock()
+	b.finished[broadcastID] = true
+	b.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package handlertest provides in-memory fakes of the bot and the user
+// repository and a harness that replays conversations against the handlers
+// without a Telegram token or a database.
+package handlertest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Button is an inline keyboard button as the user sees it
+type Button struct {
+	Text string
+	Data string
+}
+
+// Sent is one outgoing message recorded by FakeBot
+type Sent struct {
+	ChatID  int64
+	Kind    string // text, photo, document
+	Text    string // message text or caption
+	Buttons [][]Button
+}
+
+// FakeBot implements handlers.Bot and records everything the handlers send
+type FakeBot struct {
+	mu     sync.Mutex
+	sent   []Sent
+	nextID int
+}
+
+func NewFakeBot() *FakeBot {
+	return &FakeBot{}
+}
+
+func (b *FakeBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var s Sent
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		s = Sent{ChatID: v.ChatID, Kind: "text", Text: v.Text, Buttons: buttonsOf(v.ReplyMarkup)}
+	case tgbotapi.PhotoConfig:
+		s = Sent{ChatID: v.ChatID, Kind: "photo", Text: v.Caption, Buttons: buttonsOf(v.ReplyMarkup)}
+	case tgbotapi.DocumentConfig:
+		s = Sent{ChatID: v.ChatID, Kind: "document", Text: v.Caption, Buttons: buttonsOf(v.ReplyMarkup)}
+	default:
+		return tgbotapi.Message{}, fmt.Errorf("fakebot: unsupported %T", c)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent = append(b.sent, s)
+	b.nextID++
+	return tgbotapi.Message{MessageID: b.nextID, Chat: &tgbotapi.Chat{ID: s.ChatID}, Text: s.Text}, nil
+}
+
+// Request accepts callback answers and other requests without a visible result
+func (b *FakeBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// Messages returns a copy of everything sent so far
+func (b *FakeBot) Messages() []Sent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Sent(nil), b.sent...)
+}
+
+// Since returns the messages sent after the first n
+func (b *FakeBot) Since(n int) []Sent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n >= len(b.sent) {
+		return nil
+	}
+	return append([]Sent(nil), b.sent[n:]...)
+}
+
+// Len returns the number of messages sent so far
+func (b *FakeBot) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sent)
+}
+
+// Transcript renders messages as readable text, keyboards as [text|data] lines
+func Transcript(msgs []Sent) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&sb, "<- %d (%s): %s\n", m.ChatID, m.Kind, m.Text)
+		for _, row := range m.Buttons {
+			sb.WriteString("   ")
+			for _, btn := range row {
+				fmt.Fprintf(&sb, " [%s|%s]", btn.Text, btn.Data)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func buttonsOf(markup interface{}) [][]Button {
+	kb, ok := markup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok {
+		return nil
+	}
+	rows := make([][]Button, 0, len(kb.InlineKeyboard))
+	for _, row := range kb.InlineKeyboard {
+		var r []Button
+		for _, btn := range row {
+			data := ""
+			if btn.CallbackData != nil {
+				data = *btn.CallbackData
+			}
+			r = append(r, Button{Text: btn.Text, Data: data})
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
@@ -0,0 +1,241 @@
+package handlertest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"tgbot/handlers"
+	"tgbot/profiles"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Harness drives the handlers the way Telegram would: commands, text and
+// button presses from a user, with all replies captured by FakeBot.
+// Each harness has its own in-memory stores, so harnesses can run in parallel.
+type Harness struct {
+	Bot        *FakeBot
+	Users      *MemoryUsers
+	Settings   *MemorySettings
+	Waitlist   *MemoryWaitlist
+	Jobs       *MemoryJobs
+	Matches    *MemoryMatches
+	Admins     *MemoryAdmins
+	Broadcasts *MemoryBroadcasts
+	Checkins   *MemoryCheckins
+	Mgr        *states.Manager
+	Profiles   *profiles.Fake // аккаунты chess.com, которые «существуют»
+	Deps       *handlers.Deps // то, что получают обработчики
+
+	ctx        context.Context
+	updateID   int
+	background sync.WaitGroup // рассылки и проверки аккаунтов, запущенные обработчиками в фоне
+}
+
+// OrganizerID is the Telegram ID the harness sends organizer commands from
+const OrganizerID int64 = 1
+
+// organizerCommands are the organizer commands the harness can send; access
+// rights are checked in main, so the harness calls the handlers directly
+var organizerCommands = map[string]func(context.Context, handlers.Bot, *handlers.Deps, tgbotapi.Update){
+	"checkin":     handlers.HandleCheckin,
+	"bracket_gen": handlers.HandleBracketGenerate,
+	"bracket":     handlers.HandleBracketView,
+}
+
+func New() *Harness {
+	users := NewMemoryUsers()
+	h := &Harness{
+		Bot:        NewFakeBot(),
+		Users:      users,
+		Settings:   NewMemorySettings(),
+		Waitlist:   NewMemoryWaitlist(),
+		Jobs:       NewMemoryJobs(),
+		Matches:    NewMemoryMatches(),
+		Admins:     NewMemoryAdmins(),
+		Broadcasts: NewMemoryBroadcasts(users),
+		Checkins:   NewMemoryCheckins(),
+		Mgr:        states.NewManager(),
+		Profiles:   profiles.NewFake(),
+		ctx:        context.Background(),
+	}
+	h.Deps = &handlers.Deps{
+		Users:              h.Users,
+		Settings:           h.Settings,
+		Waitlist:           h.Waitlist,
+		Jobs:               h.Jobs,
+		Matches:            h.Matches,
+		Admins:             h.Admins,
+		Broadcasts:         h.Broadcasts,
+		Checkins:           h.Checkins,
+		Verifiers:          map[string]profiles.Verifier{"chess.com": h.Profiles},
+		SessionRemindAfter: 6 * time.Hour,
+		SessionTTL:         72 * time.Hour,
+		Background:         &h.background,
+	}
+	return h
+}
+
+// Start sends /start from the user
+func (h *Harness) Start(userID int64) {
+	handlers.HandleStart(h.ctx, h.Bot, h.Deps, h.Mgr, h.message(userID, "/start"))
+}
+
+// Edit sends /edit from the user
+func (h *Harness) Edit(userID int64) {
+	handlers.HandleEdit(h.ctx, h.Bot, h.Deps, h.Mgr, h.message(userID, "/edit"))
+}
+
+// Organizer sends an organizer command such as "/checkin open bs 2h" from
+// OrganizerID and waits for the deliveries it starts in the background
+func (h *Harness) Organizer(text string) error {
+	update := h.message(OrganizerID, text)
+	handle, ok := organizerCommands[update.Message.Command()]
+	if !ok {
+		return fmt.Errorf("organizer command %q is not supported by the harness", text)
+	}
+	handle(h.ctx, h.Bot, h.Deps, update)
+	h.background.Wait()
+	return nil
+}
+
+// Say sends a plain text message from the user and waits for the work it
+// starts in the background, such as an account lookup
+func (h *Harness) Say(userID int64, text string) {
+	handlers.HandleMessage(h.ctx, h.Bot, h.Deps, h.Mgr, h.message(userID, text))
+	h.background.Wait()
+}
+
+// Wait lets the given time pass in silence and runs the background jobs that
+// look at idle sessions, as the scheduler would
+func (h *Harness) Wait(d time.Duration) {
+	now := time.Now().Add(d)
+	handlers.RemindAbandonedSessions(h.ctx, h.Bot, h.Deps, h.Mgr, now)
+	handlers.ExpireAbandonedSessions(h.ctx, h.Deps, h.Mgr, now)
+}
+
+// Press presses an inline button. Like a real user, it can only press buttons
+// the bot has actually shown in this chat.
+func (h *Harness) Press(userID int64, data string) error {
+	if !h.offered(userID, data) {
+		return fmt.Errorf("button %q was never shown to user %d", data, userID)
+	}
+	h.updateID++
+	update := tgbotapi.Update{
+		UpdateID: h.updateID,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      fmt.Sprint(h.updateID),
+			From:    &tgbotapi.User{ID: userID},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: userID}},
+			Data:    data,
+		},
+	}
+	handlers.HandleCallback(h.ctx, h.Bot, h.Deps, h.Mgr, update)
+	return nil
+}
+
+func (h *Harness) message(userID int64, text string) tgbotapi.Update {
+	h.updateID++
+	m := &tgbotapi.Message{
+		MessageID: h.updateID,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: userID},
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		cmd := strings.Fields(text)[0]
+		m.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmd)}}
+	}
+	return tgbotapi.Update{UpdateID: h.updateID, Message: m}
+}
+
+func (h *Harness) offered(chatID int64, data string) bool {
+	for _, m := range h.Bot.Messages() {
+		if m.ChatID != chatID {
+			continue
+		}
+		for _, row := range m.Buttons {
+			for _, btn := range row {
+				if btn.Data == data {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Step is a single user action and what the bot must answer to it
+type Step struct {
+	Start     bool             // отправить /start
+	Edit      bool             // отправить /edit
+	Say       string           // отправить текст
+	Organizer string           // команда организатора от OrganizerID, например "/checkin open bs 2h"
+	Press     string           // нажать кнопку с этими callback-данными
+	Idle      time.Duration    // промолчать столько: запускаются напоминания о брошенных анкетах и их удаление
+	Meanwhile func(h *Harness) // изменить данные в обход диалога, например с другого устройства
+	Expect    string           // подстрока, которая должна быть в одном из ответов
+}
+
+// Scenario is a conversation of one user with the bot
+type Scenario struct {
+	Name   string
+	UserID int64
+	Setup  func(h *Harness) // подготовка перед диалогом, например аккаунты chess.com
+	Steps  []Step
+	Check  func(h *Harness) error // проверка состояния после диалога
+}
+
+// Run replays the scenario and returns the first failed expectation
+func (h *Harness) Run(s Scenario) error {
+	if s.Setup != nil {
+		s.Setup(h)
+	}
+	for i, st := range s.Steps {
+		before := h.Bot.Len()
+		switch {
+		case st.Start:
+			h.Start(s.UserID)
+		case st.Edit:
+			h.Edit(s.UserID)
+		case st.Press != "":
+			if err := h.Press(s.UserID, st.Press); err != nil {
+				return fmt.Errorf("%s: step %d: %w", s.Name, i+1, err)
+			}
+		case st.Idle > 0:
+			h.Wait(st.Idle)
+		case st.Meanwhile != nil:
+			st.Meanwhile(h)
+		case st.Organizer != "":
+			if err := h.Organizer(st.Organizer); err != nil {
+				return fmt.Errorf("%s: step %d: %w", s.Name, i+1, err)
+			}
+		default:
+			h.Say(s.UserID, st.Say)
+		}
+
+		replies := h.Bot.Since(before)
+		if st.Expect != "" && !contains(replies, st.Expect) {
+			return fmt.Errorf("%s: step %d: no reply contains %q, got:\n%s", s.Name, i+1, st.Expect, Transcript(replies))
+		}
+	}
+	if s.Check != nil {
+		if err := s.Check(h); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func contains(msgs []Sent, substr string) bool {
+	for _, m := range msgs {
+		if strings.Contains(m.Text, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package handlertest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tgbot/models"
+	"tgbot/profiles"
+	"tgbot/states"
+)
+
+// Scenarios returns the reference conversations for the built-in discipline
+// registry: a regular registration in two games confirmed twice, a full
+// triathlon, a registration into a full discipline, an attempt after the
+// deadline, an abandoned form that is reminded about, resumed and finally
+// expired, an edit that meets a taken tag and a withdrawal made in the
+// meantime, a check-in opened by an organizer, a check-in invite pressed after
+// the participant was deleted and a bracket that is not regenerated by
+// accident and keeps a withdrawn player
+func Scenarios() []Scenario {
+	return []Scenario{
+		{
+			Name:   "regular registration",
+			UserID: 101,
+			Steps: []Step{
+				{Start: true, Expect: "Введите ваше имя"},
+				{Say: "Иван", Expect: "Введите вашу фамилию"},
+				{Say: "Петров", Expect: "Введите ваш класс"},
+				{Say: "9А", Expect: "Выберите дисциплину"},
+				{Press: "disc_bs", Expect: "ПРАВИЛА BRAWL STARS"},
+				{Press: "ok_bs", Expect: "Введите ваш ник в Brawl Stars"},
+				{Say: "Vanya", Expect: "Введите ваш тег в Brawl Stars"},
+				{Say: "#2PQ8LJY", Expect: "Хотите зарегистрироваться в других играх?"},
+				{Press: "more_yes", Expect: "Выберите следующую игру"},
+				{Press: "disc_cr", Expect: "ПРАВИЛА CLASH ROYALE"},
+				{Press: "ok_cr", Expect: "Введите ваш ник в Clash Royale"},
+				// Пробелы в нике допустимы, в отличие от тега
+				{Say: "Vanya P", Expect: "Введите ваш тег в Clash Royale"},
+				// Тег без решётки и в нижнем регистре нормализуется
+				{Say: "9cq2", Expect: "Хотите зарегистрироваться в других играх?"},
+				{Press: "more_no", Expect: "ПРОВЕРКА ДАННЫХ"},
+				{Press: "final_confirm", Expect: "Регистрация завершена"},
+				// Повторное нажатие не затирает сохранённую анкету
+				{Press: "final_confirm", Expect: "Эта кнопка уже неактуальна"},
+			},
+			Check: expectUser(101, "Иван", "Петров", "9А", map[string]models.GameData{
+				"Brawl Stars":  {Nick: "Vanya", Tag: "#2PQ8LJY"},
+				"Clash Royale": {Nick: "Vanya P", Tag: "#9CQ2"},
+			}),
+		},
+		{
+			Name:   "triathlon",
+			UserID: 202,
+			Setup: func(h *Harness) {
+				h.Profiles.Add(profiles.Profile{Username: "Masha_Chess", URL: "https://www.chess.com/member/masha_chess", Rating: 1234, RatingOf: "рапид"})
+			},
+			Steps: []Step{
+				{Start: true, Expect: "Введите ваше имя"},
+				{Say: "Мария", Expect: "Введите вашу фамилию"},
+				{Say: "Смирнова", Expect: "Введите ваш класс"},
+				{Say: "10Б", Expect: "Выберите дисциплину"},
+				{Press: "disc_tri", Expect: "ПРАВИЛА ТРИАТЛОНА"},
+				{Press: "tri_bs", Expect: "Введите ваш ник в Brawl Stars"},
+				{Say: "Masha", Expect: "Введите ваш тег в Brawl Stars"},
+				// Опечатка O вместо 0 отклоняется с подсказкой
+				{Say: "#2PQ8LJYO", Expect: "Неверный тег"},
+				{Say: "#2PQ8LJY0", Expect: "Данные для Brawl Stars сохранены"},
+				{Press: "tri_cr", Expect: "Введите ваш ник в Clash Royale"},
+				{Say: "Masha", Expect: "Введите ваш тег в Clash Royale"},
+				{Say: "#YYQQ", Expect: "Данные для Clash Royale сохранены"},
+				{Press: "tri_ch", Expect: "Введите ваш ник в Chess"},
+				{Say: "no_such_player", Expect: "не найден"},
+				{Say: "masha_chess", Expect: "Masha_Chess (рапид: 1234)"},
+				{Press: "vrf_yes:Masha_Chess", Expect: "Данные для Chess сохранены"},
+				{Press: "vrf_yes:Masha_Chess", Expect: "Эта кнопка уже неактуальна"},
+				{Press: "tri_check", Expect: "Статус заполнения триатлона"},
+				{Press: "tri_done", Expect: "ПРОВЕРКА ДАННЫХ"},
+				{Press: "tri_confirm", Expect: "Регистрация завершена"},
+			},
+			Check: expectUser(202, "Мария", "Смирнова", "10Б", map[string]models.GameData{
+				"Brawl Stars":  {Nick: "Masha", Tag: "#2PQ8LJY0"},
+				"Clash Royale": {Nick: "Masha", Tag: "#YYQQ"},
+				"Chess":        {Nick: "Masha_Chess"},
+			}),
+		},
+		{
+			Name:   "full discipline goes to the waitlist",
+			UserID: 303,
+			Setup: func(h *Harness) {
+				ctx := context.Background()
+				h.Settings.Set(ctx, "capacity_bs", "1")
+				h.Users.Save(ctx, &models.User{TelegramID: 900, FirstName: "Олег", LastName: "Ким", Class: "11А",
+					Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Oleg", Tag: "#PPPP"}}})
+			},
+			Steps: []Step{
+				{Start: true, Expect: "Введите ваше имя"},
+				{Say: "Пётр", Expect: "Введите вашу фамилию"},
+				{Say: "Орлов", Expect: "Введите ваш класс"},
+				{Say: "8В", Expect: "Выберите дисциплину"},
+				{Press: "disc_bs", Expect: "Все места в Brawl Stars заняты"},
+				{Press: "ok_bs", Expect: "Введите ваш ник в Brawl Stars"},
+				{Say: "Petya", Expect: "Введите ваш тег в Brawl Stars"},
+				{Say: "#QQQQ", Expect: "Хотите зарегистрироваться в других играх?"},
+				{Press: "more_yes", Expect: "Выберите следующую игру"},
+				{Press: "disc_cr", Expect: "ПРАВИЛА CLASH ROYALE"},
+				{Press: "ok_cr", Expect: "Введите ваш ник в Clash Royale"},
+				{Say: "Petya", Expect: "Введите ваш тег в Clash Royale"},
+				{Say: "#QQQQ", Expect: "Хотите зарегистрироваться в других играх?"},
+				{Press: "more_no", Expect: "ПРОВЕРКА ДАННЫХ"},
+				{Press: "final_confirm", Expect: "Лист ожидания: Brawl Stars"},
+			},
+			Check: func(h *Harness) error {
+				if err := expectUser(303, "Пётр", "Орлов", "8В", map[string]models.GameData{
+					"Clash Royale": {Nick: "Petya", Tag: "#QQQQ"},
+				})(h); err != nil {
+					return err
+				}
+				e, err := h.Waitlist.Get(context.Background(), "Brawl Stars", 303)
+				if err != nil || e.Position != 1 {
+					return fmt.Errorf("user 303 is not first in the Brawl Stars waitlist: %+v, %v", e, err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:   "registration closed",
+			UserID: 404,
+			Setup: func(h *Harness) {
+				h.Settings.Set(context.Background(), "registration_closes", time.Now().Add(-time.Hour).Format(time.RFC3339))
+			},
+			Steps: []Step{
+				{Start: true, Expect: "Регистрация закрыта"},
+			},
+		},
+		{
+			Name:   "abandoned form",
+			UserID: 505,
+			Steps: []Step{
+				{Start: true, Expect: "Введите ваше имя"},
+				{Say: "Анна", Expect: "Введите вашу фамилию"},
+				{Idle: 7 * time.Hour, Expect: "остановились на шаге «фамилия»"},
+				{Press: "resume", Expect: "Введите вашу фамилию"},
+				{Say: "Лебедева", Expect: "Введите ваш класс"},
+				{Say: "7Г", Expect: "Выберите дисциплину"},
+				{Press: "disc_cr", Expect: "ПРАВИЛА CLASH ROYALE"},
+				// После ответа напоминание снова возможно, и «Продолжить» повторяет правила
+				{Idle: 7 * time.Hour, Expect: "остановились на шаге «правила Clash Royale»"},
+				{Press: "resume", Expect: "ПРАВИЛА CLASH ROYALE"},
+				{Idle: 80 * time.Hour},
+				{Press: "resume", Expect: "Незаконченной анкеты нет"},
+			},
+			Check: func(h *Harness) error {
+				if st := h.Mgr.Get(505).State; st != states.StateIdle {
+					return fmt.Errorf("expired session of user 505 is still in state %s", st)
+				}
+				return nil
+			},
+		},
+		{
+			Name:   "edit with a taken tag",
+			UserID: 606,
+			Setup: func(h *Harness) {
+				ctx := context.Background()
+				h.Users.Save(ctx, &models.User{TelegramID: 606, FirstName: "Елена", LastName: "Белова", Class: "9Б",
+					Disciplines: map[string]models.GameData{
+						"Brawl Stars":  {Nick: "Lena", Tag: "#2222"},
+						"Clash Royale": {Nick: "Lena", Tag: "#QQQQ"},
+					}})
+				h.Users.Save(ctx, &models.User{TelegramID: 607, FirstName: "Катя", LastName: "Попова", Class: "9Б",
+					Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Kate", Tag: "#8888"}}})
+			},
+			Steps: []Step{
+				{Edit: true, Expect: "РЕДАКТИРОВАНИЕ АНКЕТЫ"},
+				{Press: "edit_d_bs", Expect: "Введите ваш ник в Brawl Stars"},
+				{Say: "Lena", Expect: "Введите ваш тег в Brawl Stars"},
+				{Say: "#8888", Expect: "Изменение не сохранено"},
+				// Пока анкета открыта, участник снимается с Clash Royale с другого устройства
+				{Meanwhile: func(h *Harness) {
+					h.Users.WithdrawDiscipline(context.Background(), 606, "Clash Royale")
+				}},
+				{Say: "#9999", Expect: "Данные обновлены"},
+				{Press: "edit_done", Expect: "Редактирование завершено"},
+			},
+			Check: expectUser(606, "Елена", "Белова", "9Б", map[string]models.GameData{
+				"Brawl Stars": {Nick: "Lena", Tag: "#9999"},
+			}),
+		},
+		{
+			Name:   "check-in",
+			UserID: 707,
+			Setup: func(h *Harness) {
+				ctx := context.Background()
+				for _, u := range []*models.User{
+					{TelegramID: 707, FirstName: "Дмитрий", LastName: "Соколов", Class: "10А",
+						Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Dima", Tag: "#2Y2Y"}}},
+					{TelegramID: 708, FirstName: "Игорь", LastName: "Зайцев", Class: "10А",
+						Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Igor", Tag: "#8P8P"}}},
+				} {
+					h.Users.Save(ctx, u)
+				}
+			},
+			Steps: []Step{
+				{Organizer: "/checkin open bs 2h", Expect: "Приглашения отправляются 2 участникам"},
+				{Press: "ci_bs", Expect: "Отметка принята"},
+				{Organizer: "/checkin", Expect: "Brawl Stars: открыт"},
+			},
+			Check: func(h *Harness) error {
+				checked, _ := h.Checkins.CheckedIn(context.Background(), "Brawl Stars")
+				if len(checked) != 1 || !checked[707] {
+					return fmt.Errorf("checked in to Brawl Stars: %v, want only 707", checked)
+				}
+				return nil
+			},
+		},
+		{
+			Name:   "check-in after the participant was deleted",
+			UserID: 710,
+			Setup: func(h *Harness) {
+				h.Users.Save(context.Background(), &models.User{TelegramID: 710, FirstName: "Олег", LastName: "Титов", Class: "11Б",
+					Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Oleg", Tag: "#2Q2Q"}}})
+			},
+			Steps: []Step{
+				{Organizer: "/checkin open bs 2h", Expect: "Приглашения отправляются 1 участникам"},
+				{Meanwhile: func(h *Harness) {
+					ctx := context.Background()
+					if u, err := h.Users.GetByTelegramID(ctx, 710); err == nil {
+						h.Users.Delete(ctx, u.ID)
+					}
+				}},
+				{Press: "ci_bs", Expect: "Вы не зарегистрированы в Brawl Stars"},
+			},
+		},
+		{
+			Name:   "withdrawn player in the bracket",
+			UserID: 808,
+			Setup: func(h *Harness) {
+				ctx := context.Background()
+				for _, u := range []*models.User{
+					{TelegramID: 808, FirstName: "Анна", LastName: "Белова", Class: "9Б",
+						Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Anna", Tag: "#9Q9Q"}}},
+					{TelegramID: 809, FirstName: "Борис", LastName: "Волков", Class: "9Б",
+						Disciplines: map[string]models.GameData{"Brawl Stars": {Nick: "Boris", Tag: "#0L0L"}}},
+				} {
+					h.Users.Save(ctx, u)
+				}
+			},
+			Steps: []Step{
+				{Organizer: "/bracket_gen bs", Expect: "Сетка Brawl Stars создана"},
+				// Повторная генерация без force не стирает сетку
+				{Organizer: "/bracket_gen bs", Expect: "Сетка Brawl Stars уже создана"},
+				{Meanwhile: func(h *Harness) {
+					h.Users.WithdrawDiscipline(context.Background(), 808, "Brawl Stars")
+				}},
+				// Снявшийся остаётся в сетке под своим именем
+				{Organizer: "/bracket bs", Expect: "Анна Б. 🚫"},
+			},
+		},
+	}
+}
+
+// expectUser checks the saved registration of the user
+func expectUser(tgID int64, first, last, class string, disciplines map[string]models.GameData) func(h *Harness) error {
+	return func(h *Harness) error {
+		u, err := h.Users.GetByTelegramID(context.Background(), tgID)
+		if err != nil {
+			return fmt.Errorf("user %d not saved: %w", tgID, err)
+		}
+		if u.FirstName != first || u.LastName != last || u.Class != class {
+			return fmt.Errorf("saved %s %s %s, want %s %s %s", u.FirstName, u.LastName, u.Class, first, last, class)
+		}
+		if len(u.Disciplines) != len(disciplines) {
+			return fmt.Errorf("saved disciplines %v, want %v", u.Disciplines, disciplines)
+		}
+		for game, want := range disciplines {
+			if got := u.Disciplines[game]; got != want {
+				return fmt.Errorf("%s: saved %+v, want %+v", game, got, want)
+			}
+		}
+		if st := h.Mgr.Get(tgID).State; st != states.StateIdle {
+			return fmt.Errorf("session not reset after confirm: state %s", st)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,212 @@
+package handlertest
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"tgbot/models"
+)
+
+// MemorySettings implements handlers.Settings on a map
+type MemorySettings struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func NewMemorySettings() *MemorySettings {
+	return &MemorySettings{values: make(map[string]string)}
+}
+
+func (s *MemorySettings) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *MemorySettings) Set(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	s.values[key] = value
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemorySettings) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.values, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// MemoryWaitlist implements handlers.Waitlist on a slice kept in queue order
+type MemoryWaitlist struct {
+	mu      sync.Mutex
+	entries []models.WaitlistEntry
+}
+
+func NewMemoryWaitlist() *MemoryWaitlist {
+	return &MemoryWaitlist{}
+}
+
+func (w *MemoryWaitlist) Add(ctx context.Context, game string, tgID int64, gd models.GameData) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, e := range w.entries {
+		if e.Discipline == game && e.TelegramID == tgID {
+			w.entries[i].Data = gd
+			return nil
+		}
+	}
+	w.entries = append(w.entries, models.WaitlistEntry{Discipline: game, TelegramID: tgID, Data: gd, CreatedAt: time.Now()})
+	w.renumber()
+	return nil
+}
+
+func (w *MemoryWaitlist) Get(ctx context.Context, game string, tgID int64) (*models.WaitlistEntry, error) {
+	found := w.find(func(e models.WaitlistEntry) bool { return e.Discipline == game && e.TelegramID == tgID })
+	if len(found) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &found[0], nil
+}
+
+func (w *MemoryWaitlist) List(ctx context.Context, game string) ([]models.WaitlistEntry, error) {
+	return w.find(func(e models.WaitlistEntry) bool { return e.Discipline == game }), nil
+}
+
+func (w *MemoryWaitlist) ByUser(ctx context.Context, tgID int64) ([]models.WaitlistEntry, error) {
+	out := w.find(func(e models.WaitlistEntry) bool { return e.TelegramID == tgID })
+	sort.Slice(out, func(i, j int) bool { return out[i].Discipline < out[j].Discipline })
+	return out, nil
+}
+
+func (w *MemoryWaitlist) Expired(ctx context.Context, now time.Time) ([]models.WaitlistEntry, error) {
+	return w.find(func(e models.WaitlistEntry) bool {
+		return !e.OfferExpiresAt.IsZero() && !now.Before(e.OfferExpiresAt)
+	}), nil
+}
+
+func (w *MemoryWaitlist) Offer(ctx context.Context, game string, tgID int64, expires time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, e := range w.entries {
+		if e.Discipline == game && e.TelegramID == tgID {
+			w.entries[i].OfferExpiresAt = expires
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (w *MemoryWaitlist) Move(ctx context.Context, game string, tgID int64, position int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	from := -1
+	for i, e := range w.entries {
+		if e.Discipline == game && e.TelegramID == tgID {
+			from = i
+		}
+	}
+	if from < 0 {
+		return sql.ErrNoRows
+	}
+	moved := w.entries[from]
+	rest := append(append([]models.WaitlistEntry{}, w.entries[:from]...), w.entries[from+1:]...)
+
+	// Позиция считается только среди участников той же дисциплины
+	if position < 1 {
+		position = 1
+	}
+	at, seen := len(rest), 0
+	for i, e := range rest {
+		if e.Discipline != game {
+			continue
+		}
+		if seen++; seen == position {
+			at = i
+			break
+		}
+	}
+	w.entries = append(rest[:at], append([]models.WaitlistEntry{moved}, rest[at:]...)...)
+	w.renumber()
+	return nil
+}
+
+func (w *MemoryWaitlist) Remove(ctx context.Context, game string, tgID int64) error {
+	w.removeIf(func(e models.WaitlistEntry) bool { return e.Discipline == game && e.TelegramID == tgID })
+	return nil
+}
+
+func (w *MemoryWaitlist) RemoveUser(ctx context.Context, tgID int64) error {
+	w.removeIf(func(e models.WaitlistEntry) bool { return e.TelegramID == tgID })
+	return nil
+}
+
+func (w *MemoryWaitlist) find(keep func(models.WaitlistEntry) bool) []models.WaitlistEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []models.WaitlistEntry
+	for _, e := range w.entries {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (w *MemoryWaitlist) removeIf(drop func(models.WaitlistEntry) bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	kept := w.entries[:0]
+	for _, e := range w.entries {
+		if !drop(e) {
+			kept = append(kept, e)
+		}
+	}
+	w.entries = kept
+	w.renumber()
+}
+
+// renumber sets positions from the slice order; must be called with w.mu held
+func (w *MemoryWaitlist) renumber() {
+	next := make(map[string]int)
+	for i := range w.entries {
+		next[w.entries[i].Discipline]++
+		w.entries[i].Position = next[w.entries[i].Discipline]
+	}
+}
+
+// MemoryJobs implements handlers.Jobs on a map by key; jobs are only recorded, never run
+type MemoryJobs struct {
+	mu   sync.Mutex
+	jobs map[string]models.Job
+}
+
+func NewMemoryJobs() *MemoryJobs {
+	return &MemoryJobs{jobs: make(map[string]models.Job)}
+}
+
+func (j *MemoryJobs) Put(ctx context.Context, job models.Job) error {
+	j.mu.Lock()
+	j.jobs[job.Key] = job
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *MemoryJobs) Cancel(ctx context.Context, key string) error {
+	j.mu.Lock()
+	delete(j.jobs, key)
+	j.mu.Unlock()
+	return nil
+}
+
+// Pending returns the job scheduled under the key
+func (j *MemoryJobs) Pending(key string) (models.Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[key]
+	return job, ok
+}
@@ -0,0 +1,230 @@
+package handlertest
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"tgbot/models"
+)
+
+// MemoryMatches implements handlers.Matches on a slice; errors mirror the
+// Postgres store (sql.ErrNoRows for missing rows)
+type MemoryMatches struct {
+	mu      sync.Mutex
+	matches []models.Match
+	nextID  int64
+}
+
+func NewMemoryMatches() *MemoryMatches {
+	return &MemoryMatches{}
+}
+
+func (r *MemoryMatches) Replace(ctx context.Context, discipline string, matches []models.Match) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.matches[:0]
+	for _, m := range r.matches {
+		if m.Discipline != discipline {
+			kept = append(kept, m)
+		}
+	}
+	r.matches = kept
+	for i := range matches {
+		r.nextID++
+		matches[i].ID = r.nextID
+		r.matches = append(r.matches, matches[i])
+	}
+	return nil
+}
+
+func (r *MemoryMatches) List(ctx context.Context, discipline string) ([]models.Match, error) {
+	return r.find(func(m models.Match) bool { return m.Discipline == discipline }), nil
+}
+
+func (r *MemoryMatches) Get(ctx context.Context, id int64) (*models.Match, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.matches {
+		if m.ID == id {
+			return &m, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryMatches) Update(ctx context.Context, matches ...models.Match) error {
+	r.mu.Lock()
+	r.update(matches)
+	r.mu.Unlock()
+	return nil
+}
+
+// update copies the fields the Postgres store updates; must be called with r.mu held
+func (r *MemoryMatches) update(matches []models.Match) {
+	for _, u := range matches {
+		for i := range r.matches {
+			if m := &r.matches[i]; m.ID == u.ID {
+				m.Player1, m.Player2, m.Score1, m.Score2 = u.Player1, u.Player2, u.Score1, u.Score2
+				m.Winner, m.Status, m.ReportedBy = u.Winner, u.Status, u.ReportedBy
+			}
+		}
+	}
+}
+
+func (r *MemoryMatches) RecordResult(ctx context.Context, done models.Match, changed ...models.Match) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := false
+	for _, m := range r.matches {
+		if m.ID == done.ID && m.Status != models.MatchDone {
+			found = true
+		}
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+	r.update(append([]models.Match{done}, changed...))
+	return nil
+}
+
+func (r *MemoryMatches) SetStart(ctx context.Context, id int64, startsAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.matches {
+		if r.matches[i].ID == id {
+			r.matches[i].StartsAt = startsAt
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MemoryMatches) ByPlayer(ctx context.Context, tgID int64) ([]models.Match, error) {
+	return r.find(func(m models.Match) bool {
+		return (m.Player1 == tgID || m.Player2 == tgID) && m.Player1 != 0 && m.Player2 != 0
+	}), nil
+}
+
+// find returns matching matches in bracket order, like the Postgres store
+func (r *MemoryMatches) find(keep func(models.Match) bool) []models.Match {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.Match
+	for _, m := range r.matches {
+		if keep(m) {
+			out = append(out, m)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case a.Discipline != b.Discipline:
+			return a.Discipline < b.Discipline
+		case a.Stage != b.Stage:
+			return a.Stage < b.Stage
+		case a.GroupName != b.GroupName:
+			return a.GroupName < b.GroupName
+		case a.Round != b.Round:
+			return a.Round < b.Round
+		}
+		return a.Slot < b.Slot
+	})
+	return out
+}
+
+// MemoryCheckins implements handlers.Checkins on maps by discipline
+type MemoryCheckins struct {
+	mu      sync.Mutex
+	windows map[string]models.CheckinWindow
+	checked map[string]map[int64]bool
+}
+
+func NewMemoryCheckins() *MemoryCheckins {
+	return &MemoryCheckins{
+		windows: make(map[string]models.CheckinWindow),
+		checked: make(map[string]map[int64]bool),
+	}
+}
+
+func (c *MemoryCheckins) Open(ctx context.Context, game string, closes time.Time, remindEvery time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.windows[game] = models.CheckinWindow{Discipline: game, OpensAt: now, ClosesAt: closes, RemindEvery: remindEvery, RemindedAt: now}
+	return nil
+}
+
+func (c *MemoryCheckins) Close(ctx context.Context, game string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows[game]
+	now := time.Now()
+	if !ok || !w.ClosesAt.After(now) {
+		return sql.ErrNoRows
+	}
+	w.ClosesAt = now
+	c.windows[game] = w
+	return nil
+}
+
+func (c *MemoryCheckins) Reset(ctx context.Context, game string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.windows, game)
+	delete(c.checked, game)
+	return nil
+}
+
+func (c *MemoryCheckins) Window(ctx context.Context, game string) (*models.CheckinWindow, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.windows[game]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &w, nil
+}
+
+func (c *MemoryCheckins) Windows(ctx context.Context) ([]models.CheckinWindow, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []models.CheckinWindow
+	for _, w := range c.windows {
+		out = append(out, w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Discipline < out[j].Discipline })
+	return out, nil
+}
+
+func (c *MemoryCheckins) MarkReminded(ctx context.Context, game string, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w, ok := c.windows[game]; ok {
+		w.RemindedAt = at
+		c.windows[game] = w
+	}
+	return nil
+}
+
+func (c *MemoryCheckins) CheckIn(ctx context.Context, game string, tgID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked[game] == nil {
+		c.checked[game] = make(map[int64]bool)
+	}
+	c.checked[game][tgID] = true
+	return nil
+}
+
+func (c *MemoryCheckins) CheckedIn(ctx context.Context, game string) (map[int64]bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make(map[int64]bool, len(c.checked[game]))
+	for id := range c.checked[game] {
+		ids[id] = true
+	}
+	return ids, nil
+}
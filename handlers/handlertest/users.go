@@ -0,0 +1,322 @@
+package handlertest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tgbot/database"
+	"tgbot/models"
+)
+
+// MemoryUsers implements handlers.UserRepository on a map; errors mirror the
+// Postgres store (sql.ErrNoRows for missing rows)
+type MemoryUsers struct {
+	mu     sync.Mutex
+	byTG   map[int64]*models.User
+	nextID int64
+}
+
+func NewMemoryUsers() *MemoryUsers {
+	return &MemoryUsers{byTG: make(map[int64]*models.User)}
+}
+
+func (r *MemoryUsers) Save(ctx context.Context, u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byTG[u.TelegramID]
+	if !ok {
+		r.nextID++
+		stored = &models.User{ID: r.nextID, TelegramID: u.TelegramID, CreatedAt: time.Now()}
+		r.byTG[u.TelegramID] = stored
+	}
+	id, created := stored.ID, stored.CreatedAt
+	*stored = *cloneUser(u)
+	stored.ID, stored.CreatedAt, stored.Status = id, created, models.UserActive
+	u.ID = id
+	return nil
+}
+
+func (r *MemoryUsers) GetByTelegramID(ctx context.Context, tgID int64) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return cloneUser(u), nil
+}
+
+func (r *MemoryUsers) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.byTG {
+		if u.ID == id {
+			return cloneUser(u), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryUsers) List(ctx context.Context, f database.UserFilter, limit, offset int) ([]models.User, int, error) {
+	found := r.filter(func(u *models.User) bool {
+		if _, ok := u.Disciplines[f.Discipline]; f.Discipline != "" && !ok {
+			return false
+		}
+		if f.Class != "" && !strings.EqualFold(u.Class, f.Class) {
+			return false
+		}
+		if f.Status != "" && u.Status != f.Status {
+			return false
+		}
+		if !f.From.IsZero() && u.CreatedAt.Before(f.From) {
+			return false
+		}
+		return f.To.IsZero() || u.CreatedAt.Before(f.To)
+	})
+	if limit <= 0 {
+		return found, len(found), nil
+	}
+	return page(found, limit, offset), len(found), nil
+}
+
+func (r *MemoryUsers) CountByDiscipline(ctx context.Context) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int)
+	for _, u := range r.byTG {
+		for game := range u.Disciplines {
+			counts[game]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *MemoryUsers) FindByGameField(ctx context.Context, game, field, value string) ([]models.User, error) {
+	return r.filter(func(u *models.User) bool {
+		gd, ok := u.Disciplines[game]
+		return ok && u.Status == models.UserActive && strings.EqualFold(gameField(gd, field), value)
+	}), nil
+}
+
+func (r *MemoryUsers) Conflicts(ctx context.Context) ([]models.Conflict, error) {
+	type key struct{ game, field, value string }
+	byKey := make(map[key]*models.Conflict)
+	var conflicts []*models.Conflict
+	for _, u := range r.filter(func(u *models.User) bool { return u.Status == models.UserActive }) {
+		for game, gd := range u.Disciplines {
+			for _, field := range []string{"nick", "tag"} {
+				value := gameField(gd, field)
+				if value == "" {
+					continue
+				}
+				k := key{game, field, strings.ToLower(value)}
+				c, ok := byKey[k]
+				if !ok {
+					c = &models.Conflict{Discipline: game, Field: field, Value: value}
+					byKey[k] = c
+					conflicts = append(conflicts, c)
+				}
+				c.TelegramIDs = append(c.TelegramIDs, u.TelegramID)
+			}
+		}
+	}
+
+	var out []models.Conflict
+	for _, c := range conflicts {
+		if len(c.TelegramIDs) > 1 {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Discipline != b.Discipline {
+			return a.Discipline < b.Discipline
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		return strings.ToLower(a.Value) < strings.ToLower(b.Value)
+	})
+	return out, nil
+}
+
+func (r *MemoryUsers) Search(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	q := strings.ToLower(query)
+	found := r.filter(func(u *models.User) bool {
+		if strings.Contains(strings.ToLower(u.FirstName), q) || strings.Contains(strings.ToLower(u.LastName), q) {
+			return true
+		}
+		for _, gd := range u.Disciplines {
+			if strings.Contains(strings.ToLower(gd.Nick), q) || strings.Contains(strings.ToLower(gd.Tag), q) {
+				return true
+			}
+		}
+		return false
+	})
+	return page(found, limit, offset), len(found), nil
+}
+
+func (r *MemoryUsers) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tgID, u := range r.byTG {
+		if u.ID == id {
+			delete(r.byTG, tgID)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *MemoryUsers) WithdrawDiscipline(ctx context.Context, tgID int64, game string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := u.Disciplines[game]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(u.Disciplines, game)
+	if len(u.Disciplines) == 0 {
+		u.Status = models.UserWithdrawn
+	}
+	return nil
+}
+
+func (r *MemoryUsers) AddDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.Disciplines[game] = gd
+	u.Status = models.UserActive
+	return nil
+}
+
+func (r *MemoryUsers) SetDiscipline(ctx context.Context, tgID int64, game string, gd models.GameData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := u.Disciplines[game]; !ok {
+		return sql.ErrNoRows
+	}
+	u.Disciplines[game] = gd
+	return nil
+}
+
+func (r *MemoryUsers) SetProfileField(ctx context.Context, tgID int64, field, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	switch field {
+	case "first_name":
+		u.FirstName = value
+	case "last_name":
+		u.LastName = value
+	case "class":
+		u.Class = value
+	default:
+		return fmt.Errorf("unknown profile field %q", field)
+	}
+	return nil
+}
+
+func (r *MemoryUsers) Withdraw(ctx context.Context, tgID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byTG[tgID]
+	if !ok || u.Status == models.UserWithdrawn {
+		return sql.ErrNoRows
+	}
+	u.Disciplines = make(map[string]models.GameData)
+	u.Status = models.UserWithdrawn
+	return nil
+}
+
+// filter returns copies of matching users ordered by ID
+func (r *MemoryUsers) filter(keep func(*models.User) bool) []models.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.User
+	for _, u := range r.byTG {
+		if keep(u) {
+			out = append(out, *cloneUser(u))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func gameField(gd models.GameData, field string) string {
+	if field == "tag" {
+		return gd.Tag
+	}
+	return gd.Nick
+}
+
+func page(users []models.User, limit, offset int) []models.User {
+	if offset >= len(users) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[offset:end]
+}
+
+func cloneUser(u *models.User) *models.User {
+	c := *u
+	c.Disciplines = make(map[string]models.GameData, len(u.Disciplines))
+	for k, v := range u.Disciplines {
+		c.Disciplines[k] = v
+	}
+	return &c
+}
+
+// Stats counts users like database.UserRepository.Stats; unfinished forms live
+// in the session store, so InProgress stays zero
+func (r *MemoryUsers) Stats(ctx context.Context, triathlon []string) (*database.RegistrationStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := &database.RegistrationStats{ByDiscipline: make(map[string]int)}
+	for _, u := range r.byTG {
+		if u.Status == models.UserWithdrawn {
+			st.Withdrawn++
+		} else {
+			st.Active++
+		}
+		for game := range u.Disciplines {
+			st.ByDiscipline[game]++
+		}
+		if len(triathlon) > 0 && hasAll(u.Disciplines, triathlon) {
+			st.Triathlon++
+		}
+	}
+	return st, nil
+}
+
+func hasAll(disciplines map[string]models.GameData, games []string) bool {
+	for _, g := range games {
+		if _, ok := disciplines[g]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"tgbot/models"
+	"tgbot/scheduler"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Виды отложенных задач
+const (
+	jobWaitlistExpiry      = "waitlist_expiry"      // снять просроченные предложения мест
+	jobCheckinReminders    = "checkin_reminders"    // напомнить о чек-ине
+	jobSessionReminders    = "session_reminders"    // напомнить о брошенных анкетах
+	jobSessionExpiry       = "session_expiry"       // удалить давно брошенные анкеты
+	jobRegistrationClosing = "registration_closing" // предупредить, что регистрация скоро закроется
+	jobMatchReminder       = "match_reminder"       // напомнить игрокам о матче
+)
+
+const (
+	registrationClosingNotice = 24 * time.Hour   // за сколько предупреждать о закрытии регистрации
+	matchReminderNotice       = 15 * time.Minute // за сколько напоминать о матче
+)
+
+// RegisterJobs подключает обработчики отложенных задач бота к планировщику
+// и заводит периодические задачи
+func RegisterJobs(ctx context.Context, s *scheduler.Scheduler, bot Bot, deps *Deps, mgr *states.Manager) error {
+	s.Handle(jobRegistrationClosing, func(ctx context.Context, job models.Job) error {
+		return runRegistrationClosing(ctx, bot, deps, mgr, job)
+	})
+	s.Handle(jobMatchReminder, func(ctx context.Context, job models.Job) error {
+		return runMatchReminder(ctx, bot, deps, job)
+	})
+
+	recurring := []struct {
+		kind     string
+		interval time.Duration
+		run      scheduler.Handler
+	}{
+		{jobWaitlistExpiry, time.Minute, func(ctx context.Context, job models.Job) error {
+			ExpireWaitlistOffers(ctx, deps, bot)
+			return nil
+		}},
+		{jobCheckinReminders, time.Minute, func(ctx context.Context, job models.Job) error {
+			SendCheckinReminders(ctx, bot, deps)
+			return nil
+		}},
+		{jobSessionReminders, 15 * time.Minute, func(ctx context.Context, job models.Job) error {
+			return RemindAbandonedSessions(ctx, bot, deps, mgr, time.Now())
+		}},
+		{jobSessionExpiry, time.Hour, func(ctx context.Context, job models.Job) error {
+			return ExpireAbandonedSessions(ctx, deps, mgr, time.Now())
+		}},
+	}
+	for _, r := range recurring {
+		if err := s.Every(ctx, r.kind, r.interval, r.run); err != nil {
+			return fmt.Errorf("schedule %s: %w", r.kind, err)
+		}
+	}
+	return nil
+}
+
+// decodePayload разбирает данные задачи
+func decodePayload(job models.Job, v any) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return fmt.Errorf("job %d payload: %w", job.ID, err)
+	}
+	return nil
+}
+
+// putJob ставит разовую задачу на время at; данные задачи кодируются в JSON
+func putJob(ctx context.Context, deps *Deps, key, kind string, at time.Time, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return deps.Jobs.Put(ctx, models.Job{Key: key, Kind: kind, RunAt: at, Payload: data})
+}
+
+// stuckState сообщает, может ли участник застрять в этом состоянии анкеты.
+// Рассылки организаторов не в счёт
+func stuckState(s states.State, mode string) bool {
+	return s != states.StateIdle && mode != states.ModeBroadcast
+}
+
+// RemindAbandonedSessions один раз напоминает о каждой анкете, к моменту now
+// брошенной дольше deps.SessionRemindAfter. Новый ответ участника снимает отметку,
+// так что о следующей остановке напомнят снова
+func RemindAbandonedSessions(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, now time.Time) error {
+	ids, err := mgr.Stale(now.Add(-deps.SessionRemindAfter))
+	if err != nil {
+		return err
+	}
+	sent := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s := mgr.Snapshot(id)
+		// Отмечаем и рассылки организаторов, чтобы не перебирать их при каждом запуске
+		mgr.MarkReminded(id, now)
+		// Анкету, которую вот-вот удалят, уже не предлагаем продолжить
+		if !stuckState(s.State, s.Mode) || !s.LastActive.After(now.Add(-deps.SessionTTL)) {
+			continue
+		}
+		msg := tgbotapi.NewMessage(id, fmt.Sprintf("👋 Вы не закончили анкету — остановились на шаге «%s». "+
+			"Нажмите «Продолжить», чтобы вернуться к нему. Через %s без ответа анкета будет удалена.",
+			stepName(s), formatHours(deps.SessionTTL-deps.SessionRemindAfter)))
+		msg.ReplyMarkup = resumeKeyboard()
+		bot.Send(msg)
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("Reminded %d users about unfinished forms", sent)
+	}
+	return nil
+}
+
+// registrationClosingPayload — данные предупреждения о закрытии регистрации
+type registrationClosingPayload struct {
+	Closes time.Time `json:"closes"`
+}
+
+// scheduleRegistrationClosing ставит предупреждение за registrationClosingNotice
+// до закрытия регистрации; нулевое closes или уже прошедшее время снимает его.
+// Предупреждение одно, поэтому ключом служит вид задачи
+func scheduleRegistrationClosing(ctx context.Context, deps *Deps, closes time.Time) error {
+	if closes.IsZero() || !closes.After(time.Now()) {
+		return deps.Jobs.Cancel(ctx, jobRegistrationClosing)
+	}
+	at := closes.Add(-registrationClosingNotice)
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	return putJob(ctx, deps, jobRegistrationClosing, jobRegistrationClosing, at, registrationClosingPayload{Closes: closes})
+}
+
+// runRegistrationClosing предупреждает тех, кто не закончил анкету, что регистрация скоро закроется
+func runRegistrationClosing(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, job models.Job) error {
+	var p registrationClosingPayload
+	if err := decodePayload(job, &p); err != nil {
+		return err
+	}
+	// Срок могли перенести после того, как задача была поставлена
+	if closes, ok := timeSetting(ctx, deps, settingRegistrationCloses); !ok || !closes.Equal(p.Closes) {
+		return nil
+	}
+
+	ids, err := mgr.Unfinished()
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("⏰ Регистрация закрывается %s, а ваша анкета ещё не заполнена. "+
+		"Нажмите «Продолжить», чтобы вернуться к анкете.",
+		deps.formatTime(p.Closes, deadlineLayout))
+	sent := 0
+	for _, id := range ids {
+		s := mgr.Snapshot(id)
+		if !stuckState(s.State, s.Mode) || s.Mode == states.ModeEdit {
+			continue
+		}
+		msg := tgbotapi.NewMessage(id, text)
+		msg.ReplyMarkup = resumeKeyboard()
+		bot.Send(msg)
+		sent++
+	}
+	notifyAdmin(bot, deps, fmt.Sprintf("⏰ Регистрация закрывается %s. Напоминание отправлено %d участникам с незаконченной анкетой.",
+		deps.formatTime(p.Closes, deadlineLayout), sent))
+	return nil
+}
+
+// matchReminderKey — ключ напоминания о матче
+func matchReminderKey(id int64) string {
+	return fmt.Sprintf("%s:%d", jobMatchReminder, id)
+}
+
+// matchReminderPayload — данные напоминания о матче
+type matchReminderPayload struct {
+	MatchID  int64     `json:"match_id"`
+	StartsAt time.Time `json:"starts_at"`
+}
+
+// scheduleMatchReminder ставит напоминание игрокам за matchReminderNotice до матча;
+// если время матча не назначено или прошло, снимает его
+func scheduleMatchReminder(ctx context.Context, deps *Deps, m models.Match) error {
+	if m.StartsAt.IsZero() || !m.StartsAt.After(time.Now()) {
+		return deps.Jobs.Cancel(ctx, matchReminderKey(m.ID))
+	}
+	at := m.StartsAt.Add(-matchReminderNotice)
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	return putJob(ctx, deps, matchReminderKey(m.ID), jobMatchReminder, at, matchReminderPayload{MatchID: m.ID, StartsAt: m.StartsAt})
+}
+
+// runMatchReminder напоминает обоим игрокам о скором матче
+func runMatchReminder(ctx context.Context, bot Bot, deps *Deps, job models.Job) error {
+	var p matchReminderPayload
+	if err := decodePayload(job, &p); err != nil {
+		return err
+	}
+	m, err := deps.Matches.Get(ctx, p.MatchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Сетку пересоздали — матча больше нет
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Матч уже сыгран, перенесён или соперник ещё не определён
+	if m.Status != models.MatchPending || !m.StartsAt.Equal(p.StartsAt) || m.Player1 == 0 || m.Player2 == 0 {
+		return nil
+	}
+
+	for _, id := range []int64{m.Player1, m.Player2} {
+		bot.Send(tgbotapi.NewMessage(id, fmt.Sprintf("⚔️ В %s начинается ваш матч #%d в %s против %s. Удачи!",
+			deps.formatTime(m.StartsAt, "15:04"), m.ID, m.Discipline, opponentName(ctx, deps, *m, id))))
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tgbot/models"
+	"tgbot/registry"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ключи настроек окна регистрации (RFC3339)
+const (
+	settingRegistrationOpens  = "registration_opens"
+	settingRegistrationCloses = "registration_closes"
+)
+
+// capacityMu не даёт двум участникам одновременно занять последнее место в дисциплине
+var capacityMu sync.Mutex
+
+// capacityKey — ключ настройки с лимитом участников дисциплины
+func capacityKey(d registry.Discipline) string {
+	return "capacity_" + d.Code
+}
+
+// registrationClosed сообщает пользователю, если регистрация ещё не открыта или уже закрыта
+func registrationClosed(ctx context.Context, bot Bot, deps *Deps, chatID int64) bool {
+	now := time.Now()
+	if opens, ok := timeSetting(ctx, deps, settingRegistrationOpens); ok && now.Before(opens) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("⏳ Регистрация откроется %s.", deps.formatTime(opens, deadlineLayout))))
+		return true
+	}
+	if closes, ok := timeSetting(ctx, deps, settingRegistrationCloses); ok && !now.Before(closes) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔒 Регистрация закрыта %s.", deps.formatTime(closes, deadlineLayout))))
+		return true
+	}
+	return false
+}
+
+// disciplineCapacity возвращает лимит участников дисциплины; ok=false — без ограничений
+func disciplineCapacity(ctx context.Context, deps *Deps, d registry.Discipline) (int, bool) {
+	value, ok, err := deps.Settings.Get(ctx, capacityKey(d))
+	if err != nil {
+		log.Printf("Error loading %s: %v", capacityKey(d), err)
+		return 0, false
+	}
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Bad %s value %q: %v", capacityKey(d), value, err)
+		return 0, false
+	}
+	return n, true
+}
+
+// hasSpot сообщает, найдётся ли пользователю место в дисциплине.
+// Тот, кто уже в ней зарегистрирован, своё место не теряет
+func hasSpot(ctx context.Context, deps *Deps, d registry.Discipline, userID int64) (bool, error) {
+	capacity, ok := disciplineCapacity(ctx, deps, d)
+	if !ok {
+		return true, nil
+	}
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	switch {
+	case err == nil:
+		if _, registered := u.Disciplines[d.Name]; registered {
+			return true, nil
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+	counts, err := deps.Users.CountByDiscipline(ctx)
+	if err != nil {
+		return false, err
+	}
+	reserved, err := reservedSpots(ctx, deps, d.Name, userID)
+	if err != nil {
+		return false, err
+	}
+	return counts[d.Name]+reserved < capacity, nil
+}
+
+// warnIfFull предупреждает при выборе дисциплины, что мест нет и участник попадёт в лист ожидания
+func warnIfFull(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, d registry.Discipline) {
+	free, err := hasSpot(ctx, deps, d, userID)
+	if err != nil {
+		log.Printf("Error checking capacity of %s: %v", d.Name, err)
+		return
+	}
+	if !free {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"⚠️ Все места в %s заняты. Вы можете заполнить данные — после подтверждения регистрации вы попадёте в лист ожидания и получите место, как только оно освободится.", d.Name)))
+	}
+}
+
+// saveRegistration сохраняет анкету, отправляя дисциплины без свободных мест в лист ожидания.
+// Возвращает сохранённую анкету, игры в листе ожидания и игры, из которых участник ушёл
+// при повторной регистрации (их места нужно отдать следующим в очереди)
+func saveRegistration(ctx context.Context, deps *Deps, temp *models.User) (saved *models.User, waiting, freed []string, err error) {
+	capacityMu.Lock()
+	defer capacityMu.Unlock()
+
+	previous, err := deps.Users.GetByTelegramID(ctx, temp.TelegramID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil, err
+	}
+
+	u := *temp
+	u.Disciplines = make(map[string]models.GameData, len(temp.Disciplines))
+	queued := make(map[string]models.GameData)
+	for _, d := range registry.All() {
+		gd, picked := temp.Disciplines[d.Name]
+		if !picked {
+			continue
+		}
+		free, err := hasSpot(ctx, deps, d, u.TelegramID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if free {
+			u.Disciplines[d.Name] = gd
+		} else {
+			queued[d.Name] = gd
+			waiting = append(waiting, d.Name)
+		}
+	}
+
+	if err := deps.Users.Save(ctx, &u); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Очереди, из которых участник ушёл при повторной регистрации, освобождаем
+	entries, err := deps.Waitlist.ByUser(ctx, u.TelegramID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, e := range entries {
+		if _, ok := queued[e.Discipline]; !ok {
+			if err := deps.Waitlist.Remove(ctx, e.Discipline, u.TelegramID); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	for _, game := range waiting {
+		if err := deps.Waitlist.Add(ctx, game, u.TelegramID, queued[game]); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if previous != nil {
+		for game := range previous.Disciplines {
+			if _, kept := u.Disciplines[game]; !kept {
+				freed = append(freed, game)
+			}
+		}
+	}
+	return &u, waiting, freed, nil
+}
+
+// HandleRegistration показывает и задаёт окно регистрации:
+// /registration, /registration open 01.03.2026 18:00, /registration close off
+func HandleRegistration(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		sendRegistrationStatus(ctx, bot, deps, chatID)
+		return
+	}
+
+	key := map[string]string{"open": settingRegistrationOpens, "close": settingRegistrationCloses}[args[0]]
+	value := strings.Join(args[1:], " ")
+	if key == "" || value == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /registration open|close <01.03.2026 18:00|off>"))
+		return
+	}
+
+	var t time.Time
+	if value == "off" {
+		if err := deps.Settings.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting %s: %v", key, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении настройки."))
+			return
+		}
+	} else {
+		var err error
+		t, err = deps.parseTime(value)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверный формат даты. Пример: /registration close 01.03.2026 18:00"))
+			return
+		}
+		if err := deps.Settings.Set(ctx, key, t.Format(time.RFC3339)); err != nil {
+			log.Printf("Error saving %s: %v", key, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении настройки."))
+			return
+		}
+	}
+	if key == settingRegistrationCloses {
+		if err := scheduleRegistrationClosing(ctx, deps, t); err != nil {
+			log.Printf("Error scheduling registration closing reminder: %v", err)
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Срок сохранён, но напоминание о закрытии регистрации не запланировано."))
+		}
+	}
+	sendRegistrationStatus(ctx, bot, deps, chatID)
+}
+
+// HandleCapacity задаёт лимит участников дисциплины: /capacity bs 32, /capacity bs off
+func HandleCapacity(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		sendRegistrationStatus(ctx, bot, deps, chatID)
+		return
+	}
+	usage := "Использование: /capacity <" + disciplineCodes() + "> <число|off>"
+	if len(args) != 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	d, ok := registry.ByCode(args[0])
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+
+	var err error
+	if args[1] == "off" {
+		err = deps.Settings.Delete(ctx, capacityKey(d))
+	} else {
+		n, convErr := strconv.Atoi(args[1])
+		if convErr != nil || n < 1 {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Лимит должен быть положительным числом."))
+			return
+		}
+		err = deps.Settings.Set(ctx, capacityKey(d), strconv.Itoa(n))
+	}
+	if err != nil {
+		log.Printf("Error saving %s: %v", capacityKey(d), err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении настройки."))
+		return
+	}
+
+	// Лимит могли поднять или снять — раздаём новые места листу ожидания
+	promoteWaitlist(ctx, bot, deps, d.Name)
+	sendRegistrationStatus(ctx, bot, deps, chatID)
+}
+
+// sendRegistrationStatus показывает окно регистрации и заполненность дисциплин
+func sendRegistrationStatus(ctx context.Context, bot Bot, deps *Deps, chatID int64) {
+	text := "📝 РЕГИСТРАЦИЯ\n\n"
+	if opens, ok := timeSetting(ctx, deps, settingRegistrationOpens); ok {
+		text += fmt.Sprintf("Открывается: %s\n", deps.formatTime(opens, deadlineLayout))
+	} else {
+		text += "Открывается: сразу\n"
+	}
+	if closes, ok := timeSetting(ctx, deps, settingRegistrationCloses); ok {
+		text += fmt.Sprintf("Закрывается: %s\n", deps.formatTime(closes, deadlineLayout))
+	} else {
+		text += "Закрывается: без срока\n"
+	}
+
+	counts, err := deps.Users.CountByDiscipline(ctx)
+	if err != nil {
+		log.Printf("Error counting users: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке статистики."))
+		return
+	}
+	text += "\n🎮 Места:\n"
+	for _, d := range registry.All() {
+		if capacity, ok := disciplineCapacity(ctx, deps, d); ok {
+			text += fmt.Sprintf("• %s: %d из %d\n", d.Name, counts[d.Name], capacity)
+		} else {
+			text += fmt.Sprintf("• %s: %d, без лимита\n", d.Name, counts[d.Name])
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
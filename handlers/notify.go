@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// notifyAdmin отправляет сообщение в чат организаторов
+func notifyAdmin(bot Bot, deps *Deps, text string) {
+	if deps.AdminChatID == 0 {
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(deps.AdminChatID, text))
+}
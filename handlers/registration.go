@@ -1,10 +1,11 @@
 package handlers
 
 import (
-    "database/sql"
+    "context"
     "fmt"
     "log"
     "tgbot/models"
+    "tgbot/registry"
     "tgbot/states"
     "tgbot/utils"
 
@@ -12,7 +13,7 @@ import (
 )
 
 // HandleMessage обрабатывает текстовые сообщения пользователя
-func HandleMessage(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, update tgbotapi.Update) {
+func HandleMessage(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, update tgbotapi.Update) {
     if update.Message == nil || update.Message.From == nil {
         return
     }
@@ -21,40 +22,58 @@ func HandleMessage(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, update
     chatID := update.Message.Chat.ID
     text := update.Message.Text
     s := mgr.Get(user.ID)
+    // Любой ответ, даже отклонённый, — признак того, что анкету не бросили
+    mgr.Touch(user.ID)
 
     switch s.State {
     case states.WaitingName:
-        handleNameInput(bot, mgr, user.ID, chatID, text)
+        handleNameInput(ctx, bot, deps, mgr, user.ID, chatID, text)
     case states.WaitingLastName:
-        handleLastNameInput(bot, mgr, user.ID, chatID, text)
+        handleLastNameInput(ctx, bot, deps, mgr, user.ID, chatID, text)
     case states.WaitingClass:
-        handleClassInput(bot, mgr, user.ID, chatID, text)
+        handleClassInput(ctx, bot, deps, mgr, user.ID, chatID, text)
     case states.EnteringNick:
-        handleNickInput(bot, mgr, user.ID, chatID, text)
+        handleNickInput(ctx, bot, deps, mgr, user.ID, chatID, text)
     case states.EnteringTag:
-        handleTagInput(bot, db, mgr, user.ID, chatID, text)
+        handleTagInput(ctx, bot, deps, mgr, user.ID, chatID, text)
+    case states.BroadcastClass:
+        handleBroadcastClass(bot, mgr, user.ID, chatID, text)
+    case states.BroadcastContent:
+        handleBroadcastContent(ctx, bot, deps, mgr, user.ID, chatID, update.Message)
     default:
         log.Printf("Unhandled state: %v for user %d", s.State, user.ID)
     }
 }
 
-func handleNameInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64, text string) {
+func handleNameInput(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, text string) {
     s := mgr.Get(userID)
     s.Temp.FirstName = text
+    if s.Mode == states.ModeEdit {
+        saveEdit(ctx, bot, deps, mgr, userID, chatID)
+        return
+    }
     mgr.SetState(userID, states.WaitingLastName)
     bot.Send(tgbotapi.NewMessage(chatID, "Введите вашу фамилию:"))
 }
 
-func handleLastNameInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64, text string) {
+func handleLastNameInput(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, text string) {
     s := mgr.Get(userID)
     s.Temp.LastName = text
+    if s.Mode == states.ModeEdit {
+        saveEdit(ctx, bot, deps, mgr, userID, chatID)
+        return
+    }
     mgr.SetState(userID, states.WaitingClass)
     bot.Send(tgbotapi.NewMessage(chatID, "Введите ваш класс (например: 9А, 10Б):"))
 }
 
-func handleClassInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64, text string) {
+func handleClassInput(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, text string) {
     s := mgr.Get(userID)
     s.Temp.Class = text
+    if s.Mode == states.ModeEdit {
+        saveEdit(ctx, bot, deps, mgr, userID, chatID)
+        return
+    }
     mgr.SetState(userID, states.ChoosingDiscipline)
 
     msg := tgbotapi.NewMessage(chatID, "Выберите дисциплину для участия:")
@@ -62,7 +81,7 @@ func handleClassInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, c
     bot.Send(msg)
 }
 
-func handleNickInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64, text string) {
+func handleNickInput(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, text string) {
     s := mgr.Get(userID)
 
     if s.CurrentGame == "" {
@@ -71,30 +90,68 @@ func handleNickInput(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, ch
         return
     }
 
+    d, ok := registry.ByName(s.CurrentGame)
+    if !ok {
+        bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка: игра не найдена. Начните заново с /start"))
+        mgr.SetState(userID, states.ChoosingDiscipline)
+        return
+    }
+
+    // Проверяем ник по правилам дисциплины
+    nick, err := d.NormalizeNick(text)
+    if err != nil {
+        bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Неверный ник в %s. %v\n\nВведите ник ещё раз:", d.Name, err)))
+        return
+    }
+
+    // Проверяем, что аккаунт существует (для дисциплин с проверкой профиля);
+    // продолжим после подтверждения кнопкой (vrf_yes)
+    if d.Verify != "" && startVerification(ctx, bot, deps, mgr, userID, chatID, d, nick) {
+        return
+    }
+
     // Сохраняем ник в дисциплину
     gd := s.Temp.Disciplines[s.CurrentGame]
-    gd.Nick = text
+    gd.Nick = nick
     s.Temp.Disciplines[s.CurrentGame] = gd
 
-    // Для шахмат тег не требуется
-    if s.CurrentGame == "Chess" {
-        handlePostChessNick(bot, mgr, userID, chatID)
+    continueAfterNick(ctx, bot, deps, mgr, userID, chatID, d)
+}
+
+// continueAfterNick переходит к вводу тега или завершает ввод данных дисциплины
+func continueAfterNick(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, d registry.Discipline) {
+    // Тег нужен не во всех дисциплинах (например, в шахматах только ник)
+    if !d.NeedsTag() {
+        handlePostNick(ctx, bot, deps, mgr, userID, chatID)
     } else {
-        // Для BS и CR требуется тег
         mgr.SetState(userID, states.EnteringTag)
-        bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш тег в %s (например: #ABC123):", s.CurrentGame)))
+        bot.Send(tgbotapi.NewMessage(chatID, tagPrompt(d)))
+    }
+}
+
+// tagPrompt — вопрос о теге в дисциплине с примером из её правил
+func tagPrompt(d registry.Discipline) string {
+    example := d.Tag.Example
+    if example == "" {
+        example = "#ABC123"
     }
+    return fmt.Sprintf("Введите ваш тег в %s (например: %s):", d.Name, example)
 }
 
-func handlePostChessNick(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64) {
+// handlePostNick завершает ввод данных для дисциплины без тега
+func handlePostNick(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64) {
     s := mgr.Get(userID)
+    if s.Mode == states.ModeEdit {
+        saveEdit(ctx, bot, deps, mgr, userID, chatID)
+        return
+    }
 
     // Проверяем, в режиме ли триатлона (если есть флаг или проверяем TriGames)
     isTriathlon := len(s.TriGames) > 0
 
     if isTriathlon {
         // Триатлон: возвращаемся к выбору игр
-        msg := tgbotapi.NewMessage(chatID, "✅ Данные для Chess сохранены!\n\nВыберите следующую игру:")
+        msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Данные для %s сохранены!\n\nВыберите следующую игру:", s.CurrentGame))
         msg.ReplyMarkup = getTriathlonKeyboard(s.Temp.Disciplines)
         bot.Send(msg)
         mgr.SetState(userID, states.TriathlonSelect)
@@ -104,20 +161,33 @@ func handlePostChessNick(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64
     }
 }
 
-func handleTagInput(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, userID int64, chatID int64, text string) {
+func handleTagInput(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID int64, chatID int64, text string) {
     s := mgr.Get(userID)
 
-    // Валидируем формат тега
-    if !utils.ValidateTag(text) {
-        bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверный формат тега! Используйте формат #ABC123"))
+    d, ok := registry.ByName(s.CurrentGame)
+    if !ok {
+        bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка: игра не найдена. Начните заново с /start"))
+        mgr.SetState(userID, states.ChoosingDiscipline)
+        return
+    }
+
+    // Валидируем и нормализуем тег по правилам дисциплины
+    tag, err := d.NormalizeTag(text)
+    if err != nil {
+        bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Неверный тег в %s. %v\n\nВведите тег ещё раз:", d.Name, err)))
         return
     }
 
     // Сохраняем тег
     gd := s.Temp.Disciplines[s.CurrentGame]
-    gd.Tag = text
+    gd.Tag = tag
     s.Temp.Disciplines[s.CurrentGame] = gd
 
+    if s.Mode == states.ModeEdit {
+        saveEdit(ctx, bot, deps, mgr, userID, chatID)
+        return
+    }
+
     // Проверяем, в режиме ли триатлона
     isTriathlon := len(s.TriGames) > 0
 
@@ -133,7 +203,7 @@ func handleTagInput(bot *tgbotapi.BotAPI, db *sql.DB, mgr *states.Manager, userI
     }
 }
 
-func askMoreDisciplines(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64, chatID int64) {
+func askMoreDisciplines(bot Bot, mgr *states.Manager, userID int64, chatID int64) {
     kb := tgbotapi.NewInlineKeyboardMarkup(
         tgbotapi.NewInlineKeyboardRow(
             tgbotapi.NewInlineKeyboardButtonData("Да", "more_yes"),
@@ -148,24 +218,15 @@ func askMoreDisciplines(bot *tgbotapi.BotAPI, mgr *states.Manager, userID int64,
 
 // getTriathlonKeyboard создает клавиатуру для выбора игр триатлона
 func getTriathlonKeyboard(disciplines map[string]models.GameData) tgbotapi.InlineKeyboardMarkup {
-    games := []struct {
-        name string
-        code string
-    }{
-        {"Brawl Stars", "tri_bs"},
-        {"Clash Royale", "tri_cr"},
-        {"Chess", "tri_ch"},
-    }
-
     rows := [][]tgbotapi.InlineKeyboardButton{}
 
-    for _, game := range games {
+    for _, game := range registry.Triathlon() {
         status := "⬜"
-        if gd, ok := disciplines[game.name]; ok && gd.Nick != "" {
+        if gd, ok := disciplines[game.Name]; ok && gd.Nick != "" {
             status = "✅"
         }
         rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-            tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s", status, game.name), game.code),
+            tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s", status, game.Name), "tri_"+game.Code),
         ))
     }
 
@@ -184,16 +245,15 @@ func getTriathlonKeyboard(disciplines map[string]models.GameData) tgbotapi.Inlin
     return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
-// isTriathlonComplete проверяет, заполнены ли все 3 игры для триатлона
+// isTriathlonComplete проверяет, заполнены ли все игры триатлона
 func isTriathlonComplete(disciplines map[string]models.GameData) bool {
-    requiredGames := []string{"Brawl Stars", "Clash Royale", "Chess"}
-    for _, game := range requiredGames {
-        gd, ok := disciplines[game]
+    for _, game := range registry.Triathlon() {
+        gd, ok := disciplines[game.Name]
         if !ok || gd.Nick == "" {
             return false
         }
-        // Для BS и CR требуется также тег
-        if game != "Chess" && gd.Tag == "" {
+        // Там, где нужен тег, он тоже должен быть заполнен
+        if game.NeedsTag() && gd.Tag == "" {
             return false
         }
     }
@@ -203,22 +263,20 @@ func isTriathlonComplete(disciplines map[string]models.GameData) bool {
 // getTriathlonStatus возвращает текст со статусом заполнения
 func getTriathlonStatus(disciplines map[string]models.GameData) string {
     status := "📊 Статус заполнения триатлона:\n\n"
-    games := []string{"Brawl Stars", "Clash Royale", "Chess"}
-
-    for _, game := range games {
+    for _, game := range registry.Triathlon() {
         icon := "⬜"
         details := "не заполнено"
 
-        if gd, ok := disciplines[game]; ok && gd.Nick != "" {
+        if gd, ok := disciplines[game.Name]; ok && gd.Nick != "" {
             icon = "✅"
-            if game == "Chess" {
-                details = fmt.Sprintf("ник: %s", gd.Nick)
-            } else {
+            if game.NeedsTag() {
                 details = fmt.Sprintf("ник: %s, тег: %s", gd.Nick, gd.Tag)
+            } else {
+                details = fmt.Sprintf("ник: %s", gd.Nick)
             }
         }
 
-        status += fmt.Sprintf("%s %s: %s\n", icon, game, details)
+        status += fmt.Sprintf("%s %s: %s\n", icon, game.Name, details)
     }
 
     return status
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/tournament"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleReport показывает игроку его несыгранные матчи для отправки результата: /report
+func HandleReport(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	matches, err := deps.Matches.ByPlayer(ctx, userID)
+	if err != nil {
+		log.Printf("Error loading matches of %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке матчей. Попробуйте позже."))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range matches {
+		if m.Status != models.MatchPending {
+			continue
+		}
+		label := fmt.Sprintf("#%d %s: против %s", m.ID, m.Discipline, opponentName(ctx, deps, m, userID))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("rep_m_%d", m.ID)),
+		))
+	}
+	if len(rows) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "У вас нет несыгранных матчей."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Выберите матч, результат которого хотите отправить:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+// HandleSetResult позволяет админу выставить результат матча: /result 12 2 1
+func HandleSetResult(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	var id int64
+	var s1, s2 int
+	if _, err := fmt.Sscan(update.Message.CommandArguments(), &id, &s1, &s2); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /result <id матча> <счёт игрока 1> <счёт игрока 2>"))
+		return
+	}
+
+	m, err := deps.Matches.Get(ctx, id)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Матч #%d не найден.", id)))
+		return
+	}
+	if m.Status == models.MatchDone {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Матч #%d уже завершён.", id)))
+		return
+	}
+	err = applyMatchResult(ctx, bot, deps, m, s1, s2)
+	if errors.Is(err, errMatchDone) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Матч #%d уже завершён.", id)))
+		return
+	}
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось сохранить результат: %v", err)))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Результат матча #%d сохранён: %d:%d", id, s1, s2)))
+}
+
+// handleReportCallback обрабатывает кнопки отправки результата:
+// rep_m_<id> — выбор матча, rep_s_<id>_<мой>_<соперника> — выбор счёта,
+// rep_ok_<id> / rep_no_<id> — подтверждение или спор соперника
+func handleReportCallback(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, data string) {
+	parts := strings.Split(data, "_")
+	if len(parts) < 3 {
+		log.Printf("Bad report callback: %s", data)
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		log.Printf("Bad report callback: %s", data)
+		return
+	}
+
+	m, err := deps.Matches.Get(ctx, id)
+	if err != nil {
+		log.Printf("Error loading match %d: %v", id, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Матч не найден."))
+		return
+	}
+	if m.Player1 != userID && m.Player2 != userID {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Вы не участвуете в этом матче."))
+		return
+	}
+
+	switch parts[1] {
+	case "m":
+		handleReportMatch(bot, m, chatID)
+	case "s":
+		if len(parts) != 5 {
+			log.Printf("Bad report callback: %s", data)
+			return
+		}
+		mine, err1 := strconv.Atoi(parts[3])
+		theirs, err2 := strconv.Atoi(parts[4])
+		if err1 != nil || err2 != nil {
+			log.Printf("Bad report callback: %s", data)
+			return
+		}
+		handleReportScore(ctx, bot, deps, m, userID, chatID, tournament.Score{Mine: mine, Theirs: theirs})
+	case "ok":
+		handleReportConfirm(ctx, bot, deps, m, userID, chatID)
+	case "no":
+		handleReportDispute(ctx, bot, deps, m, userID, chatID)
+	default:
+		log.Printf("Unknown report callback: %s", data)
+	}
+}
+
+// handleReportMatch предлагает выбрать счёт по правилам дисциплины
+func handleReportMatch(bot Bot, m *models.Match, chatID int64) {
+	if m.Status != models.MatchPending {
+		bot.Send(tgbotapi.NewMessage(chatID, "Результат этого матча уже отправлен."))
+		return
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, sc := range tournament.FormatOf(m.Discipline).ReportOptions(m.Stage) {
+		label := fmt.Sprintf("Победа %d:%d", sc.Mine, sc.Theirs)
+		if sc.Draw() {
+			label = "Ничья"
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("rep_s_%d_%d_%d", m.ID, sc.Mine, sc.Theirs)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Матч #%d (%s). Выберите итоговый счёт:", m.ID, m.Discipline))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons)
+	bot.Send(msg)
+}
+
+// handleReportScore сохраняет заявленный счёт и просит соперника его подтвердить
+func handleReportScore(ctx context.Context, bot Bot, deps *Deps, m *models.Match, userID, chatID int64, sc tournament.Score) {
+	if m.Status != models.MatchPending {
+		bot.Send(tgbotapi.NewMessage(chatID, "Результат этого матча уже отправлен."))
+		return
+	}
+
+	s1, s2 := sc.Mine, sc.Theirs
+	opponent := m.Player2
+	if m.Player2 == userID {
+		s1, s2 = s2, s1
+		opponent = m.Player1
+	}
+	if err := tournament.FormatOf(m.Discipline).Validate(m.Stage, s1, s2); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Такой счёт невозможен по правилам дисциплины."))
+		return
+	}
+
+	m.Score1, m.Score2 = s1, s2
+	m.Status = models.MatchReported
+	m.ReportedBy = userID
+	if err := deps.Matches.Update(ctx, *m); err != nil {
+		log.Printf("Error saving report for match %d: %v", m.ID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении результата. Попробуйте позже."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "✅ Результат отправлен. Ждём подтверждения соперника."))
+
+	result := fmt.Sprintf("%d:%d в пользу соперника", sc.Mine, sc.Theirs)
+	if sc.Draw() {
+		result = "ничья"
+	}
+	msg := tgbotapi.NewMessage(opponent, fmt.Sprintf(
+		"⚔️ Матч #%d (%s)\nСоперник сообщил результат: %s.\n\nПодтверждаете?",
+		m.ID, m.Discipline, result))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтверждаю", fmt.Sprintf("rep_ok_%d", m.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ Оспорить", fmt.Sprintf("rep_no_%d", m.ID)),
+		),
+	)
+	bot.Send(msg)
+}
+
+// handleReportConfirm засчитывает результат после подтверждения соперником
+func handleReportConfirm(ctx context.Context, bot Bot, deps *Deps, m *models.Match, userID, chatID int64) {
+	if m.Status != models.MatchReported || m.ReportedBy == userID {
+		bot.Send(tgbotapi.NewMessage(chatID, "Этот результат не ждёт вашего подтверждения."))
+		return
+	}
+
+	err := applyMatchResult(ctx, bot, deps, m, m.Score1, m.Score2)
+	if errors.Is(err, errMatchDone) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Результат этого матча уже засчитан."))
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying result of match %d: %v", m.ID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении результата. Попробуйте позже."))
+		return
+	}
+}
+
+// handleReportDispute передаёт спорный результат организаторам
+func handleReportDispute(ctx context.Context, bot Bot, deps *Deps, m *models.Match, userID, chatID int64) {
+	if m.Status != models.MatchReported || m.ReportedBy == userID {
+		bot.Send(tgbotapi.NewMessage(chatID, "Этот результат не ждёт вашего подтверждения."))
+		return
+	}
+
+	m.Status = models.MatchDisputed
+	if err := deps.Matches.Update(ctx, *m); err != nil {
+		log.Printf("Error saving dispute for match %d: %v", m.ID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении. Попробуйте позже."))
+		return
+	}
+
+	text := "⚠️ Результат оспорен. Организаторы свяжутся с вами для решения спора."
+	bot.Send(tgbotapi.NewMessage(m.Player1, text))
+	bot.Send(tgbotapi.NewMessage(m.Player2, text))
+
+	users := disciplineUsers(ctx, deps, m.Discipline, []models.Match{*m})
+	notifyAdmin(bot, deps, fmt.Sprintf(
+		"⚠️ Спорный результат матча #%d (%s)\n%s %d:%d %s\nСообщил: %s, оспорил: %s\n\nЧтобы выставить результат: /result %d <счёт 1> <счёт 2>",
+		m.ID, m.Discipline,
+		playerName(users, m.Player1), m.Score1, m.Score2, playerName(users, m.Player2),
+		playerName(users, m.ReportedBy), playerName(users, userID),
+		m.ID))
+}
+
+// errMatchDone — результат матча уже засчитан
+var errMatchDone = errors.New("результат матча уже засчитан")
+
+// bracketMu не даёт двум результатам одновременно продвигать сетку: каждый читает
+// все матчи дисциплины и переписывает следующие, и второй затёр бы первый своей
+// старой копией — например, потерял бы одного из финалистов
+var bracketMu sync.Mutex
+
+// applyMatchResult засчитывает результат, продвигает сетку и уведомляет игроков
+func applyMatchResult(ctx context.Context, bot Bot, deps *Deps, m *models.Match, s1, s2 int) error {
+	matches, idx, updated, err := recordMatchResult(ctx, deps, m, s1, s2)
+	if err != nil {
+		return err
+	}
+
+	users := disciplineUsers(ctx, deps, m.Discipline, matches)
+	done := matches[idx]
+	result := fmt.Sprintf("✅ Результат матча #%d засчитан: %s %d:%d %s",
+		done.ID, playerName(users, done.Player1), done.Score1, done.Score2, playerName(users, done.Player2))
+	bot.Send(tgbotapi.NewMessage(done.Player1, result))
+	bot.Send(tgbotapi.NewMessage(done.Player2, result))
+
+	// Сообщаем игрокам о матчах плей-офф, в которых теперь известны оба соперника
+	for _, next := range updated[1:] {
+		if next.Status != models.MatchPending || next.Player1 == 0 || next.Player2 == 0 {
+			continue
+		}
+		for _, p := range []int64{next.Player1, next.Player2} {
+			other := next.Player2
+			if p == next.Player2 {
+				other = next.Player1
+			}
+			bot.Send(tgbotapi.NewMessage(p, fmt.Sprintf(
+				"🏆 Ваш следующий матч #%d (%s, %s) против %s.\nПосле игры победитель отправляет /report",
+				next.ID, next.Discipline, roundName(matches, next.Round), playerName(users, other))))
+		}
+	}
+	return nil
+}
+
+// recordMatchResult применяет результат к сетке дисциплины и сохраняет её под bracketMu.
+// Возвращает сетку, индекс матча и изменённые матчи — первым идёт сам матч
+func recordMatchResult(ctx context.Context, deps *Deps, m *models.Match, s1, s2 int) (matches []models.Match, idx int, updated []models.Match, err error) {
+	bracketMu.Lock()
+	defer bracketMu.Unlock()
+
+	matches, err = deps.Matches.List(ctx, m.Discipline)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	idx = -1
+	for i := range matches {
+		if matches[i].ID == m.ID {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, 0, nil, fmt.Errorf("match %d not found in bracket", m.ID)
+	}
+
+	changed, err := tournament.ApplyResult(matches, idx, s1, s2)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	// Первым идёт сам матч; остальные матчи могут повторяться, если посев
+	// плей-офф затронул тот же матч, что и продвижение победителя
+	updated = make([]models.Match, 0, len(changed))
+	seen := make(map[int]bool, len(changed))
+	for _, i := range changed {
+		if !seen[i] {
+			seen[i] = true
+			updated = append(updated, matches[i])
+		}
+	}
+	// Результат могли засчитать одновременно (подтверждение соперника и /result) — второй раз не применяем
+	err = deps.Matches.RecordResult(ctx, updated[0], updated[1:]...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, nil, errMatchDone
+	}
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return matches, idx, updated, nil
+}
+
+// opponentName возвращает имя соперника игрока в матче
+func opponentName(ctx context.Context, deps *Deps, m models.Match, userID int64) string {
+	other := m.Player2
+	if m.Player2 == userID {
+		other = m.Player1
+	}
+	return playerName(disciplineUsers(ctx, deps, m.Discipline, []models.Match{m}), other)
+}
+
+// disciplineUsers загружает участников дисциплины по Telegram ID; при ошибке возвращает пустую карту.
+// Игроков этих матчей, которые снялись с дисциплины, дозагружает по одному, чтобы
+// в сетке и уведомлениях осталось их имя; у них в карте Status — UserWithdrawn
+func disciplineUsers(ctx context.Context, deps *Deps, game string, matches []models.Match) map[int64]models.User {
+	users, _, err := deps.Users.List(ctx, database.UserFilter{Discipline: game}, 0, 0)
+	if err != nil {
+		log.Printf("Error listing users for %s: %v", game, err)
+	}
+	byID := usersByTelegramID(users)
+
+	missing := make(map[int64]bool)
+	for _, m := range matches {
+		for _, id := range []int64{m.Player1, m.Player2} {
+			if _, ok := byID[id]; id != 0 && !ok {
+				missing[id] = true
+			}
+		}
+	}
+	for id := range missing {
+		u, err := deps.Users.GetByTelegramID(ctx, id)
+		if err != nil {
+			// Удалённый участник остаётся в сетке под своим ID
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error loading user %d: %v", id, err)
+			}
+			continue
+		}
+		u.Status = models.UserWithdrawn
+		byID[id] = *u
+	}
+	return byID
+}
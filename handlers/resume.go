@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"tgbot/registry"
+	"tgbot/states"
+	"tgbot/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// resumeKeyboard — кнопка, которая возвращает участника к шагу анкеты, на котором он остановился
+func resumeKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Продолжить", "resume"),
+		),
+	)
+}
+
+// ExpireAbandonedSessions удаляет анкеты, к моменту now брошенные дольше
+// deps.SessionTTL, и освобождает память
+func ExpireAbandonedSessions(ctx context.Context, deps *Deps, mgr *states.Manager, now time.Time) error {
+	ids, err := mgr.Expire(now.Add(-deps.SessionTTL))
+	if len(ids) > 0 {
+		log.Printf("Expired %d unfinished sessions", len(ids))
+	}
+	return err
+}
+
+// handleResume возвращает участника к шагу анкеты по кнопке «Продолжить»
+func handleResume(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64) {
+	s := mgr.Snapshot(userID)
+	if !stuckState(s.State, s.Mode) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Незаконченной анкеты нет — возможно, она удалена за давностью. Чтобы зарегистрироваться, отправьте /start"))
+		return
+	}
+	if s.Mode == "" && registrationClosed(ctx, bot, deps, chatID) {
+		return
+	}
+	mgr.Touch(userID)
+	repromptStep(ctx, bot, deps, mgr, userID, chatID)
+}
+
+// repromptStep повторяет вопрос текущего шага анкеты
+func repromptStep(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64) {
+	s := mgr.Snapshot(userID)
+	edit := s.Mode == states.ModeEdit
+	d, known := registry.ByName(s.CurrentGame)
+
+	switch {
+	case s.State == states.WaitingName && edit:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новое имя:"))
+	case s.State == states.WaitingName:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите ваше имя:"))
+	case s.State == states.WaitingLastName && edit:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новую фамилию:"))
+	case s.State == states.WaitingLastName:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите вашу фамилию:"))
+	case s.State == states.WaitingClass && edit:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите новый класс (например: 9А, 10Б):"))
+	case s.State == states.WaitingClass:
+		bot.Send(tgbotapi.NewMessage(chatID, "Введите ваш класс (например: 9А, 10Б):"))
+	case s.State == states.ChoosingDiscipline && len(s.Temp.Disciplines) > 0:
+		askMoreDisciplines(bot, mgr, userID, chatID)
+	case s.State == states.ReadingRules && known:
+		handleDisciplineRules(ctx, bot, deps, mgr, userID, chatID, d.Code)
+	case s.State == states.EnteringNick && known:
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", d.Name)))
+	case s.State == states.EnteringTag && known:
+		bot.Send(tgbotapi.NewMessage(chatID, tagPrompt(d)))
+	case s.State == states.TriathlonSelect:
+		handleTriathlonCheck(bot, mgr, userID, chatID)
+	default:
+		// Выбор дисциплины или шаг, для которого потерялась игра
+		mgr.SetState(userID, states.ChoosingDiscipline)
+		msg := tgbotapi.NewMessage(chatID, "Выберите дисциплину для участия:")
+		msg.ReplyMarkup = utils.DisciplineKeyboard()
+		bot.Send(msg)
+	}
+}
+
+// stepName — понятное участнику название шага анкеты; s — снимок сессии (Manager.Snapshot)
+func stepName(s *states.Session) string {
+	switch s.State {
+	case states.WaitingName:
+		return "имя"
+	case states.WaitingLastName:
+		return "фамилия"
+	case states.WaitingClass:
+		return "класс"
+	case states.ChoosingDiscipline:
+		return "выбор дисциплины"
+	case states.ReadingRules:
+		return "правила " + s.CurrentGame
+	case states.EnteringNick:
+		return "ник в " + s.CurrentGame
+	case states.EnteringTag:
+		return "тег в " + s.CurrentGame
+	case states.TriathlonSelect:
+		return "игры триатлона"
+	}
+	return string(s.State)
+}
+
+// formatHours форматирует срок в часах, а короткий — в минутах: «66 ч», «40 мин»
+func formatHours(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%d мин", int(d.Round(time.Minute).Minutes()))
+	}
+	return fmt.Sprintf("%d ч", int(d.Round(time.Hour).Hours()))
+}
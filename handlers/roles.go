@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"tgbot/access"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RoleOf возвращает роль организатора; ok == false, если пользователь не в таблице admins
+func RoleOf(ctx context.Context, deps *Deps, userID int64) (access.Role, bool) {
+	role, err := deps.Admins.Role(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error checking role of %d: %v", userID, err)
+		}
+		return "", false
+	}
+	return access.Role(role), true
+}
+
+// Can сообщает, разрешено ли пользователю действие
+func Can(ctx context.Context, deps *Deps, userID int64, p access.Permission) bool {
+	role, ok := RoleOf(ctx, deps, userID)
+	return ok && role.Can(p)
+}
+
+// HandleGrant выдаёт роль организатора: /grant <tg_id> <owner|admin|moderator|referee>
+func HandleGrant(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) != 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /grant <telegram_id> <"+roleNames()+">"))
+		return
+	}
+
+	tgID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Telegram ID должен быть числом."))
+		return
+	}
+	role, ok := access.ParseRole(strings.ToLower(args[1]))
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Неизвестная роль. Доступные роли: "+roleNames()))
+		return
+	}
+
+	if ownerFromConfig(bot, deps, chatID, tgID) {
+		return
+	}
+	// Нельзя понизить последнего владельца — иначе управлять ролями будет некому
+	if current, ok := RoleOf(ctx, deps, tgID); ok && current == access.Owner && role != access.Owner {
+		if !canDropOwner(ctx, bot, deps, chatID) {
+			return
+		}
+	}
+
+	if err := deps.Admins.SetRole(ctx, tgID, string(role), update.Message.From.ID); err != nil {
+		log.Printf("Error granting %s to %d: %v", role, tgID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при выдаче роли."))
+		return
+	}
+	log.Printf("Owner %d granted %s to %d", update.Message.From.ID, role, tgID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Пользователь %d получил роль %s.", tgID, role)))
+}
+
+// HandleRevoke отзывает все права организатора: /revoke <tg_id>
+func HandleRevoke(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	tgID, err := strconv.ParseInt(strings.TrimSpace(update.Message.CommandArguments()), 10, 64)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /revoke <telegram_id>"))
+		return
+	}
+
+	if ownerFromConfig(bot, deps, chatID, tgID) {
+		return
+	}
+	current, ok := RoleOf(ctx, deps, tgID)
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Пользователь %d не является организатором.", tgID)))
+		return
+	}
+	if current == access.Owner && !canDropOwner(ctx, bot, deps, chatID) {
+		return
+	}
+
+	if err := deps.Admins.Delete(ctx, tgID); err != nil {
+		log.Printf("Error revoking %d: %v", tgID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при отзыве роли."))
+		return
+	}
+	log.Printf("Owner %d revoked %s from %d", update.Message.From.ID, current, tgID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Пользователь %d больше не %s.", tgID, current)))
+}
+
+// ownerFromConfig отказывает в смене роли владельца из ADMIN_IDS: при следующем
+// запуске бот снова сделал бы его владельцем
+func ownerFromConfig(bot Bot, deps *Deps, chatID, tgID int64) bool {
+	if !deps.configuredOwner(tgID) {
+		return false
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"❌ Пользователь %d — владелец из ADMIN_IDS: при каждом запуске бот снова делает его владельцем. "+
+			"Чтобы изменить его роль, уберите его из ADMIN_IDS и перезапустите бота.", tgID)))
+	return true
+}
+
+// HandleAdmins показывает список организаторов и их роли: /admins
+func HandleAdmins(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	admins, err := deps.Admins.List(ctx)
+	if err != nil {
+		log.Printf("Error listing admins: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке списка организаторов."))
+		return
+	}
+
+	text := "👮 ОРГАНИЗАТОРЫ\n\n"
+	for _, a := range admins {
+		text += fmt.Sprintf("• %d — %s (с %s)\n", a.TelegramID, a.Role, a.CreatedAt.Format("02.01.2006"))
+	}
+	if len(admins) == 0 {
+		text += "Список пуст."
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// canDropOwner проверяет, что после понижения останется хотя бы один владелец
+func canDropOwner(ctx context.Context, bot Bot, deps *Deps, chatID int64) bool {
+	owners, err := deps.Admins.CountOwners(ctx)
+	if err != nil {
+		log.Printf("Error counting owners: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при проверке владельцев."))
+		return false
+	}
+	if owners <= 1 {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Нельзя лишить роли последнего владельца."))
+		return false
+	}
+	return true
+}
+
+// roleNames перечисляет роли через «|» для подсказок
+func roleNames() string {
+	names := make([]string, len(access.Roles))
+	for i, r := range access.Roles {
+		names[i] = string(r)
+	}
+	return strings.Join(names, "|")
+}
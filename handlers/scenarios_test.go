@@ -0,0 +1,20 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"tgbot/handlers/handlertest"
+)
+
+// TestScenarios replays the reference conversations against the handlers with
+// a fake bot and in-memory stores
+func TestScenarios(t *testing.T) {
+	for _, s := range handlertest.Scenarios() {
+		t.Run(s.Name, func(t *testing.T) {
+			h := handlertest.New()
+			if err := h.Run(s); err != nil {
+				t.Fatalf("%v\ntranscript:\n%s", err, handlertest.Transcript(h.Bot.Messages()))
+			}
+		})
+	}
+}
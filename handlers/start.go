@@ -1,17 +1,29 @@
 package handlers
 
 import (
+	"context"
+
+	"tgbot/registry"
 	"tgbot/states"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func HandleStart(bot *tgbotapi.BotAPI, mgr *states.Manager, update tgbotapi.Update) {
+func HandleStart(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, update tgbotapi.Update) {
 	chatID := update.Message.Chat.ID
 	userID := update.Message.From.ID
+	if registrationClosed(ctx, bot, deps, chatID) {
+		return
+	}
 	mgr.Reset(userID)
 	mgr.SetState(userID, states.WaitingName)
 
-	msg := tgbotapi.NewMessage(chatID, "🎮 Добро пожаловать на регистрацию eTriathlon 2026!\n\nТурнир включает три игры:\n• Brawl Stars\n• Clash Royale\n• Chess (Шахматы)\n\nДля регистрации введите ваши данные.\n\nВведите ваше имя:")
+	text := "🎮 Добро пожаловать на регистрацию eTriathlon 2026!\n\nТурнир включает игры:\n"
+	for _, d := range registry.All() {
+		text += "• " + d.Name + "\n"
+	}
+	text += "\nДля регистрации введите ваши данные.\n\nВведите ваше имя:"
+
+	msg := tgbotapi.NewMessage(chatID, text)
 	bot.Send(msg)
 }
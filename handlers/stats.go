@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"tgbot/models"
+	"tgbot/tournament"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleMyStats показывает участнику его анкету, матчи и место в группе: /mystats
+func HandleMyStats(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы ещё не зарегистрированы. Используйте /start для регистрации."))
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading user %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+
+	text := "📋 ВАШИ ДАННЫЕ\n\n" + formatUserData(u)
+	if u.Status == models.UserWithdrawn {
+		text += "\n🚫 Вы сняты с турнира.\n"
+	}
+
+	matches, err := deps.Matches.ByPlayer(ctx, userID)
+	if err != nil {
+		log.Printf("Error loading matches of %d: %v", userID, err)
+	} else if len(matches) > 0 {
+		text += "\n" + formatPlayerMatches(ctx, deps, userID, matches)
+	}
+
+	sendLong(bot, chatID, text)
+}
+
+// formatPlayerMatches форматирует историю матчей игрока и его места в группах
+func formatPlayerMatches(ctx context.Context, deps *Deps, userID int64, matches []models.Match) string {
+	var b strings.Builder
+	b.WriteString("⚔️ Матчи:\n")
+
+	users := make(map[string]map[int64]models.User)
+	for _, m := range matches {
+		if users[m.Discipline] == nil {
+			users[m.Discipline] = disciplineUsers(ctx, deps, m.Discipline, matches)
+		}
+		other, mine, theirs := m.Player2, m.Score1, m.Score2
+		if m.Player2 == userID {
+			other, mine, theirs = m.Player1, m.Score2, m.Score1
+		}
+
+		result := "⏳ не сыгран"
+		switch {
+		case m.Status == models.MatchReported:
+			result = "🕓 ждёт подтверждения"
+		case m.Status == models.MatchDisputed:
+			result = "⚠️ спор"
+		case m.Status != models.MatchDone:
+		case m.Winner == userID:
+			result = fmt.Sprintf("✅ победа %d:%d", mine, theirs)
+		case m.Winner == 0:
+			result = fmt.Sprintf("🤝 ничья %d:%d", mine, theirs)
+		default:
+			result = fmt.Sprintf("❌ поражение %d:%d", mine, theirs)
+		}
+		fmt.Fprintf(&b, "  #%d %s, %s — против %s: %s\n", m.ID, m.Discipline, stageName(m), playerName(users[m.Discipline], other), result)
+	}
+
+	// Место в группе по каждой дисциплине
+	var standings []string
+	for game := range users {
+		bracket, err := deps.Matches.List(ctx, game)
+		if err != nil {
+			log.Printf("Error loading bracket for %s: %v", game, err)
+			continue
+		}
+		for group, table := range tournament.Standings(bracket) {
+			for i, st := range table {
+				if st.Player == userID {
+					standings = append(standings, fmt.Sprintf("  %s, группа %s: %d место, %d о. (%d-%d-%d)",
+						game, group, i+1, st.Points, st.Wins, st.Draws, st.Losses))
+				}
+			}
+		}
+	}
+	// Дисциплины и группы хранятся в картах — упорядочиваем строки, чтобы они не прыгали
+	sort.Strings(standings)
+	if len(standings) > 0 {
+		b.WriteString("\n📊 Положение в группах:\n" + strings.Join(standings, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// stageName возвращает название этапа матча: «группа A» или раунд плей-офф
+func stageName(m models.Match) string {
+	if m.Stage == models.StageGroup {
+		return "группа " + m.GroupName
+	}
+	return fmt.Sprintf("плей-офф, раунд %d", m.Round)
+}
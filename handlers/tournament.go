@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tgbot/database"
+	"tgbot/models"
+	"tgbot/registry"
+	"tgbot/tournament"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleBracketGenerate генерирует группы и сетку плей-офф для дисциплины: /bracket_gen bs.
+// Существующую сетку заменяет только /bracket_gen bs force — вместе с ней пропадают результаты и расписание
+func HandleBracketGenerate(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	force := len(args) == 2 && args[1] == "force"
+	if len(args) == 0 || len(args) > 2 || len(args) == 2 && !force {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /bracket_gen "+disciplineCodes()+" [force]"))
+		return
+	}
+	d, ok := registry.ByCode(args[0])
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /bracket_gen "+disciplineCodes()+" [force]"))
+		return
+	}
+	game := d.Name
+
+	// Пока сетка пересоздаётся, результаты в неё не записываются
+	bracketMu.Lock()
+	defer bracketMu.Unlock()
+
+	existing, err := deps.Matches.List(ctx, game)
+	if err != nil {
+		log.Printf("Error loading bracket for %s: %v", game, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке сетки."))
+		return
+	}
+	if len(existing) > 0 && !force {
+		played := 0
+		for _, m := range existing {
+			if m.Status != models.MatchPending {
+				played++
+			}
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"⚠️ Сетка %s уже создана: %d матчей, с результатом или спором — %d.\n"+
+				"Новая сетка заменит её вместе с результатами и расписанием. Чтобы пересоздать, отправьте /bracket_gen %s force",
+			game, len(existing), played, d.Code)))
+		return
+	}
+
+	users, _, err := deps.Users.List(ctx, database.UserFilter{Discipline: game}, 0, 0)
+	if err != nil {
+		log.Printf("Error listing users for %s: %v", game, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участников."))
+		return
+	}
+	registered := len(users)
+	users, checkin, err := checkedInOnly(ctx, deps, game, users)
+	if err != nil {
+		log.Printf("Error loading check-ins for %s: %v", game, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке чек-ина."))
+		return
+	}
+
+	players := make([]int64, 0, len(users))
+	for _, u := range users {
+		players = append(players, u.TelegramID)
+	}
+	rand.Shuffle(len(players), func(i, j int) { players[i], players[j] = players[j], players[i] })
+
+	matches, err := tournament.Generate(game, players, tournament.DefaultOptions)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось создать сетку %s: недостаточно участников (%d).", game, len(players))))
+		return
+	}
+	if err := deps.Matches.Replace(ctx, game, matches); err != nil {
+		log.Printf("Error saving bracket for %s: %v", game, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении сетки."))
+		return
+	}
+
+	text := fmt.Sprintf("✅ Сетка %s создана: %d участников, %d матчей.", game, len(players), len(matches))
+	if checkin {
+		text += fmt.Sprintf("\nВ сетку попали только отметившиеся на чек-ине: %d из %d зарегистрированных.", len(players), registered)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+	sendLong(bot, chatID, formatBracket(deps, game, matches, usersByTelegramID(users)))
+}
+
+// HandleBracketView показывает текущее состояние сетки: /bracket bs
+func HandleBracketView(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	d, ok := registry.ByCode(strings.TrimSpace(update.Message.CommandArguments()))
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, "Использование: /bracket "+disciplineCodes()))
+		return
+	}
+	game := d.Name
+
+	matches, err := deps.Matches.List(ctx, game)
+	if err != nil {
+		log.Printf("Error loading bracket for %s: %v", game, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке сетки."))
+		return
+	}
+	if len(matches) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Сетка %s ещё не создана.", game)))
+		return
+	}
+
+	sendLong(bot, chatID, formatBracket(deps, game, matches, disciplineUsers(ctx, deps, game, matches)))
+}
+
+// HandleMatchSchedule назначает время матча и напоминание игрокам:
+// /schedule 12 01.03.2026 18:00, /schedule 12 off
+func HandleMatchSchedule(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	usage := "Использование: /schedule <id матча> <01.03.2026 18:00|off>"
+	if len(args) < 2 {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+
+	var startsAt time.Time
+	if value := strings.Join(args[1:], " "); value != "off" {
+		t, err := deps.parseTime(value)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Неверный формат даты. Пример: /schedule 12 01.03.2026 18:00"))
+			return
+		}
+		startsAt = t
+	}
+
+	err = deps.Matches.SetStart(ctx, id, startsAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Матч #%d не найден.", id)))
+		return
+	}
+	if err != nil {
+		log.Printf("Error scheduling match %d: %v", id, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+		return
+	}
+	m, err := deps.Matches.Get(ctx, id)
+	if err != nil {
+		log.Printf("Error loading match %d: %v", id, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке матча."))
+		return
+	}
+	if err := scheduleMatchReminder(ctx, deps, *m); err != nil {
+		log.Printf("Error scheduling reminder of match %d: %v", id, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Время сохранено, но напоминание игрокам не запланировано."))
+	}
+
+	if startsAt.IsZero() {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Время матча #%d снято.", id)))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Матч #%d назначен на %s. Игроки получат напоминание за %d минут.",
+		id, deps.formatTime(startsAt, deadlineLayout), int(matchReminderNotice.Minutes()))))
+	for _, player := range []int64{m.Player1, m.Player2} {
+		if player != 0 {
+			bot.Send(tgbotapi.NewMessage(player, fmt.Sprintf("🗓 Ваш матч #%d в %s назначен на %s.",
+				id, m.Discipline, deps.formatTime(startsAt, deadlineLayout))))
+		}
+	}
+}
+
+// formatBracket форматирует таблицы групп и сетку плей-офф
+func formatBracket(deps *Deps, game string, matches []models.Match, users map[int64]models.User) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏆 Турнирная сетка: %s\n", game)
+
+	tables := tournament.Standings(matches)
+	groups := make([]string, 0, len(tables))
+	for name := range tables {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+
+	for _, name := range groups {
+		fmt.Fprintf(&b, "\n📊 Группа %s:\n", name)
+		for i, st := range tables[name] {
+			fmt.Fprintf(&b, "  %d. %s — %d о. (%d-%d-%d)\n", i+1, playerName(users, st.Player), st.Points, st.Wins, st.Draws, st.Losses)
+		}
+		for _, m := range matches {
+			if m.Stage == models.StageGroup && m.GroupName == name {
+				b.WriteString("  " + formatMatch(deps, m, users) + "\n")
+			}
+		}
+	}
+
+	round := 0
+	for _, m := range matches {
+		if m.Stage != models.StagePlayoff {
+			continue
+		}
+		if m.Round != round {
+			round = m.Round
+			fmt.Fprintf(&b, "\n⚔️ %s:\n", roundName(matches, round))
+		}
+		b.WriteString("  " + formatMatch(deps, m, users) + "\n")
+	}
+	return b.String()
+}
+
+// formatMatch форматирует одну строку матча: #12 Иван П. 2:1 Пётр С. ✅
+func formatMatch(deps *Deps, m models.Match, users map[int64]models.User) string {
+	p1 := slotName(users, m.Player1, m.Source1)
+	p2 := slotName(users, m.Player2, m.Source2)
+	if m.Status == models.MatchDone {
+		if m.Player2 == 0 {
+			return fmt.Sprintf("#%d %s — проходит без игры", m.ID, p1)
+		}
+		return fmt.Sprintf("#%d %s %d:%d %s ✅", m.ID, p1, m.Score1, m.Score2, p2)
+	}
+	if m.Status == models.MatchDisputed {
+		return fmt.Sprintf("#%d %s — %s ⚠️ спор", m.ID, p1, p2)
+	}
+	if !m.StartsAt.IsZero() {
+		return fmt.Sprintf("#%d %s — %s ⏳ %s", m.ID, p1, p2, deps.formatTime(m.StartsAt, "02.01 15:04"))
+	}
+	return fmt.Sprintf("#%d %s — %s ⏳", m.ID, p1, p2)
+}
+
+// slotName возвращает имя игрока или ссылку на место в группе, если игрок ещё не определён
+func slotName(users map[int64]models.User, id int64, source string) string {
+	if id != 0 {
+		return playerName(users, id)
+	}
+	if source != "" {
+		return source
+	}
+	return "?"
+}
+
+// playerName возвращает короткое имя участника: Иван П., снявшегося — Иван П. 🚫
+func playerName(users map[int64]models.User, id int64) string {
+	u, ok := users[id]
+	if !ok {
+		return fmt.Sprintf("id%d", id)
+	}
+	name := u.FirstName
+	if last := []rune(u.LastName); len(last) > 0 {
+		name = fmt.Sprintf("%s %s.", u.FirstName, string(last[0]))
+	}
+	if u.Status == models.UserWithdrawn {
+		name += " 🚫"
+	}
+	return name
+}
+
+// roundName возвращает название раунда плей-офф по количеству матчей в нём
+func roundName(matches []models.Match, round int) string {
+	n := 0
+	for _, m := range matches {
+		if m.Stage == models.StagePlayoff && m.Round == round {
+			n++
+		}
+	}
+	if n == 1 {
+		return "Финал"
+	}
+	return fmt.Sprintf("1/%d финала", n)
+}
+
+// disciplineCodes возвращает коды дисциплин для подсказок в командах: bs|cr|ch
+func disciplineCodes() string {
+	var codes []string
+	for _, d := range registry.All() {
+		codes = append(codes, d.Code)
+	}
+	return strings.Join(codes, "|")
+}
+
+func usersByTelegramID(users []models.User) map[int64]models.User {
+	byID := make(map[int64]models.User, len(users))
+	for _, u := range users {
+		byID[u.TelegramID] = u
+	}
+	return byID
+}
+
+// sendLong отправляет длинный текст несколькими сообщениями (лимит Telegram — 4096 символов)
+func sendLong(bot Bot, chatID int64, text string) {
+	const limit = 4000
+	var chunk strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if chunk.Len()+len(line) > limit && chunk.Len() > 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, chunk.String()))
+			chunk.Reset()
+		}
+		chunk.WriteString(line)
+	}
+	if chunk.Len() > 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, chunk.String()))
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tgbot/profiles"
+	"tgbot/registry"
+	"tgbot/states"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// verifyTimeout ограничивает ожидание ответа сервиса проверки аккаунтов
+const verifyTimeout = 5 * time.Second
+
+// startVerification запускает в фоне поиск аккаунта игрока на платформе дисциплины
+// и возвращает false, если для неё нет сервиса проверки. Проверяемый ник
+// запоминается в сессии (Verifying): кнопки ответа действуют, только пока он не сменился.
+// Ник принимается кнопкой vrf_yes — и найденный, и непроверенный из-за недоступности сервиса
+func startVerification(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, d registry.Discipline, nick string) bool {
+	v := deps.Verifiers[d.Verify]
+	if v == nil {
+		return false
+	}
+
+	mgr.Get(userID).Verifying = nick
+	mgr.Save(userID)
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔎 Ищу аккаунт «%s» на %s…", nick, d.Verify)))
+
+	// Ответ сервиса может идти секунды — не держим остальные сообщения пользователя
+	deps.spawn(func() { lookupProfile(ctx, bot, v, chatID, d, nick) })
+	return true
+}
+
+// lookupProfile ищет аккаунт и присылает результат с кнопками подтверждения.
+// Работает в фоне, поэтому сессию не трогает: ник передаётся в данных кнопки
+func lookupProfile(ctx context.Context, bot Bot, v profiles.Verifier, chatID int64, d registry.Discipline, nick string) {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	p, err := v.Lookup(ctx, nick)
+	if errors.Is(err, profiles.ErrNotFound) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Аккаунт «%s» на %s не найден. Проверьте ник и введите его ещё раз:", nick, d.Verify)))
+		return
+	}
+	if err != nil {
+		log.Printf("Profile lookup %s/%s failed: %v", d.Verify, nick, err)
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("⚠️ Не удалось проверить аккаунт на %s. Принять ник «%s» без проверки?", d.Verify, nick))
+		msg.ReplyMarkup = verifyKeyboard(nick, "✅ Принять")
+		bot.Send(msg)
+		return
+	}
+
+	// Ник принимается в написании платформы
+	found := p.Username
+	if p.RatingOf != "" {
+		rating := "ещё нет рейтинга"
+		if p.Rating > 0 {
+			rating = fmt.Sprint(p.Rating)
+		}
+		found += fmt.Sprintf(" (%s: %s)", p.RatingOf, rating)
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔎 Найден аккаунт %s: %s\n%s\n\nЭто ваш аккаунт?", d.Verify, found, p.URL))
+	msg.ReplyMarkup = verifyKeyboard(p.Username, "✅ Да, это я")
+	bot.Send(msg)
+}
+
+// verifyKeyboard — кнопки vrf_yes:<ник> и vrf_no:<ник>
+func verifyKeyboard(nick, accept string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(accept, "vrf_yes:"+nick),
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Ввести заново", "vrf_no:"+nick),
+		),
+	)
+}
+
+// handleVerifyCallback обрабатывает ответ на проверку аккаунта: vrf_yes:<ник>, vrf_no:<ник>.
+// Кнопки действуют, только пока этот ник ждёт проверки
+func handleVerifyCallback(ctx context.Context, bot Bot, deps *Deps, mgr *states.Manager, userID, chatID int64, data string) {
+	action, nick, _ := strings.Cut(data, ":")
+	s := mgr.Get(userID)
+	d, ok := registry.ByName(s.CurrentGame)
+	// Платформа может вернуть ник в другом регистре
+	if s.State != states.EnteringNick || !ok || s.Verifying == "" || !strings.EqualFold(nick, s.Verifying) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Эта кнопка уже неактуальна."))
+		return
+	}
+
+	s.Verifying = ""
+	if action == "vrf_no" {
+		mgr.Save(userID)
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите ваш ник в %s:", d.Name)))
+		return
+	}
+
+	gd := s.Temp.Disciplines[d.Name]
+	gd.Nick = nick
+	s.Temp.Disciplines[d.Name] = gd
+	mgr.Save(userID)
+	continueAfterNick(ctx, bot, deps, mgr, userID, chatID, d)
+}
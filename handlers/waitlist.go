@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"tgbot/database"
+	"tgbot/registry"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// waitlistOfferTTL — сколько у участника из листа ожидания есть времени, чтобы занять освободившееся место
+const waitlistOfferTTL = 12 * time.Hour
+
+// reservedSpots считает места дисциплины, предложенные участникам из листа ожидания
+// и ещё не занятые (кроме предложения самому except)
+func reservedSpots(ctx context.Context, deps *Deps, game string, except int64) (int, error) {
+	entries, err := deps.Waitlist.List(ctx, game)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	n := 0
+	for _, e := range entries {
+		if e.TelegramID != except && e.Offered(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// promoteWaitlist предлагает свободные места дисциплины следующим в листе ожидания.
+// Место придерживается за участником на waitlistOfferTTL, пока он не нажмёт «Занять место»
+func promoteWaitlist(ctx context.Context, bot Bot, deps *Deps, game string) {
+	d, ok := registry.ByName(game)
+	if !ok {
+		return
+	}
+	capacityMu.Lock()
+	defer capacityMu.Unlock()
+
+	entries, err := deps.Waitlist.List(ctx, game)
+	if err != nil {
+		log.Printf("Error loading waitlist of %s: %v", game, err)
+		return
+	}
+	now := time.Now()
+
+	// Без лимита место найдётся каждому
+	free := len(entries)
+	if capacity, limited := disciplineCapacity(ctx, deps, d); limited {
+		counts, err := deps.Users.CountByDiscipline(ctx)
+		if err != nil {
+			log.Printf("Error counting users: %v", err)
+			return
+		}
+		free = capacity - counts[game]
+		for _, e := range entries {
+			if e.Offered(now) {
+				free--
+			}
+		}
+	}
+
+	expires := now.Add(waitlistOfferTTL)
+	for _, e := range entries {
+		if free <= 0 {
+			return
+		}
+		// Просроченные предложения снимает ExpireWaitlistOffers, второй раз их не делаем
+		if !e.OfferExpiresAt.IsZero() {
+			continue
+		}
+		if err := deps.Waitlist.Offer(ctx, game, e.TelegramID, expires); err != nil {
+			log.Printf("Error offering %s to %d: %v", game, e.TelegramID, err)
+			return
+		}
+		free--
+
+		log.Printf("Spot in %s offered to %d until %s", game, e.TelegramID, expires.Format(time.RFC3339))
+		msg := tgbotapi.NewMessage(e.TelegramID, fmt.Sprintf(
+			"🎉 В %s освободилось место!\nВаши данные: %s\n\nПодтвердите участие до %s — иначе место перейдёт следующему в листе ожидания.",
+			game, formatGameData(game, e.Data), deps.formatTime(expires, deadlineLayout)))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Занять место", "wl_ok_"+d.Code),
+				tgbotapi.NewInlineKeyboardButtonData("🚫 Отказаться", "wl_no_"+d.Code),
+			),
+		)
+		bot.Send(msg)
+	}
+}
+
+// handleWaitlistCallback обрабатывает ответ на предложение места: wl_ok_<код>, wl_no_<код>
+func handleWaitlistCallback(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, data string) {
+	action, code, _ := strings.Cut(strings.TrimPrefix(data, "wl_"), "_")
+	d, ok := registry.ByCode(code)
+	if !ok || (action != "ok" && action != "no") {
+		log.Printf("Unknown waitlist callback: %s from user %d", data, userID)
+		return
+	}
+
+	if action == "no" {
+		if err := deps.Waitlist.Remove(ctx, d.Name, userID); err != nil {
+			log.Printf("Error removing %d from waitlist of %s: %v", userID, d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении. Попробуйте позже."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Хорошо, вы больше не в листе ожидания %s.", d.Name)))
+		promoteWaitlist(ctx, bot, deps, d.Name)
+		return
+	}
+
+	if acceptOffer(ctx, bot, deps, userID, chatID, d) {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Вы зарегистрированы в %s! Проверить анкету: /mystats", d.Name)))
+		notifyAdmin(bot, deps, fmt.Sprintf("⬆️ Участник %d занял место в %s из листа ожидания.", userID, d.Name))
+	}
+}
+
+// acceptOffer переносит участника из листа ожидания в дисциплину, если предложение ещё действует
+func acceptOffer(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, d registry.Discipline) bool {
+	capacityMu.Lock()
+	defer capacityMu.Unlock()
+
+	e, err := deps.Waitlist.Get(ctx, d.Name, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Это предложение больше не действует."))
+		return false
+	}
+	if err != nil {
+		log.Printf("Error loading waitlist entry %d/%s: %v", userID, d.Name, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return false
+	}
+	if !e.Offered(time.Now()) {
+		bot.Send(tgbotapi.NewMessage(chatID, "⌛ Время на подтверждение истекло, место передано следующему участнику."))
+		return false
+	}
+
+	err = deps.Users.AddDiscipline(ctx, userID, d.Name, e.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Ваша анкета не найдена. Пройдите регистрацию заново: /start"))
+		return false
+	}
+	if err != nil {
+		log.Printf("Error promoting %d to %s: %v", userID, d.Name, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении. Попробуйте позже."))
+		return false
+	}
+	if err := deps.Waitlist.Remove(ctx, d.Name, userID); err != nil {
+		log.Printf("Error removing %d from waitlist of %s: %v", userID, d.Name, err)
+	}
+	log.Printf("User %d accepted a spot in %s", userID, d.Name)
+	return true
+}
+
+// ExpireWaitlistOffers снимает с листа ожидания тех, кто не занял предложенное место вовремя,
+// и предлагает их места следующим
+func ExpireWaitlistOffers(ctx context.Context, deps *Deps, bot Bot) {
+	expired, err := deps.Waitlist.Expired(ctx, time.Now())
+	if err != nil {
+		log.Printf("Error loading expired waitlist offers: %v", err)
+		return
+	}
+
+	games := make(map[string]bool)
+	for _, e := range expired {
+		if err := deps.Waitlist.Remove(ctx, e.Discipline, e.TelegramID); err != nil {
+			log.Printf("Error removing %d from waitlist of %s: %v", e.TelegramID, e.Discipline, err)
+			continue
+		}
+		games[e.Discipline] = true
+		log.Printf("Waitlist offer of %s to %d expired", e.Discipline, e.TelegramID)
+		bot.Send(tgbotapi.NewMessage(e.TelegramID, fmt.Sprintf(
+			"⌛ Время на подтверждение места в %s истекло, оно передано следующему участнику.", e.Discipline)))
+	}
+	for _, d := range registry.All() {
+		if games[d.Name] {
+			promoteWaitlist(ctx, bot, deps, d.Name)
+		}
+	}
+}
+
+// leaveWaitlists убирает участника из всех листов ожидания и возвращает игры,
+// где он ждал: его придержанные места нужно предложить следующим
+func leaveWaitlists(ctx context.Context, deps *Deps, tgID int64) []string {
+	entries, err := deps.Waitlist.ByUser(ctx, tgID)
+	if err != nil {
+		log.Printf("Error loading waitlists of %d: %v", tgID, err)
+		return nil
+	}
+	if err := deps.Waitlist.RemoveUser(ctx, tgID); err != nil {
+		log.Printf("Error removing %d from waitlists: %v", tgID, err)
+		return nil
+	}
+	games := make([]string, len(entries))
+	for i, e := range entries {
+		games[i] = e.Discipline
+	}
+	return games
+}
+
+// HandleWaitlist показывает и меняет листы ожидания:
+// /waitlist, /waitlist bs, /waitlist bs move <tg_id> <место>, /waitlist bs remove <tg_id>
+func HandleWaitlist(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		sendWaitlistSummary(ctx, bot, deps, chatID)
+		return
+	}
+
+	usage := "Использование: /waitlist <" + disciplineCodes() + "> [move <telegram_id> <место> | remove <telegram_id>]"
+	d, ok := registry.ByCode(args[0])
+	if !ok {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	if len(args) == 1 {
+		sendWaitlist(ctx, bot, deps, chatID, d)
+		return
+	}
+	if len(args) < 3 {
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	tgID, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Telegram ID должен быть числом."))
+		return
+	}
+
+	switch {
+	case args[1] == "move" && len(args) == 4:
+		position, err := strconv.Atoi(args[3])
+		if err != nil || position < 1 {
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Место в очереди должно быть положительным числом."))
+			return
+		}
+		err = deps.Waitlist.Move(ctx, d.Name, tgID, position)
+		if errors.Is(err, sql.ErrNoRows) {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Участника %d нет в листе ожидания %s.", tgID, d.Name)))
+			return
+		}
+		if err != nil {
+			log.Printf("Error moving %d in waitlist of %s: %v", tgID, d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+			return
+		}
+		log.Printf("Admin %d moved %d to place %d in waitlist of %s", update.Message.From.ID, tgID, position, d.Name)
+	case args[1] == "remove" && len(args) == 3:
+		if _, err := deps.Waitlist.Get(ctx, d.Name, tgID); errors.Is(err, sql.ErrNoRows) {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Участника %d нет в листе ожидания %s.", tgID, d.Name)))
+			return
+		}
+		if err := deps.Waitlist.Remove(ctx, d.Name, tgID); err != nil {
+			log.Printf("Error removing %d from waitlist of %s: %v", tgID, d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении."))
+			return
+		}
+		log.Printf("Admin %d removed %d from waitlist of %s", update.Message.From.ID, tgID, d.Name)
+		promoteWaitlist(ctx, bot, deps, d.Name)
+	default:
+		bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return
+	}
+	sendWaitlist(ctx, bot, deps, chatID, d)
+}
+
+// sendWaitlistSummary показывает длину листа ожидания каждой дисциплины
+func sendWaitlistSummary(ctx context.Context, bot Bot, deps *Deps, chatID int64) {
+	text := "⏳ ЛИСТЫ ОЖИДАНИЯ\n\n"
+	now := time.Now()
+	for _, d := range registry.All() {
+		entries, err := deps.Waitlist.List(ctx, d.Name)
+		if err != nil {
+			log.Printf("Error loading waitlist of %s: %v", d.Name, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке листа ожидания."))
+			return
+		}
+		offered := 0
+		for _, e := range entries {
+			if e.Offered(now) {
+				offered++
+			}
+		}
+		text += fmt.Sprintf("• %s (%s): %d в очереди, из них %d с предложенным местом\n", d.Name, d.Code, len(entries), offered)
+	}
+	text += "\nПодробнее: /waitlist <код дисциплины>"
+	bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// sendWaitlist показывает очередь дисциплины по порядку
+func sendWaitlist(ctx context.Context, bot Bot, deps *Deps, chatID int64, d registry.Discipline) {
+	entries, err := deps.Waitlist.List(ctx, d.Name)
+	if err != nil {
+		log.Printf("Error loading waitlist of %s: %v", d.Name, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке листа ожидания."))
+		return
+	}
+	if len(entries) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Лист ожидания %s пуст.", d.Name)))
+		return
+	}
+
+	users, _, err := deps.Users.List(ctx, database.UserFilter{}, 0, 0)
+	if err != nil {
+		log.Printf("Error loading users: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке участников."))
+		return
+	}
+	byID := usersByTelegramID(users)
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "⏳ ЛИСТ ОЖИДАНИЯ: %s\n\n", d.Name)
+	now := time.Now()
+	for i, e := range entries {
+		u := byID[e.TelegramID]
+		fmt.Fprintf(&text, "%d. %s %s, %s (%d) — %s", i+1, u.FirstName, u.LastName, u.Class, e.TelegramID, formatGameData(d.Name, e.Data))
+		switch {
+		case e.Offered(now):
+			fmt.Fprintf(&text, " — место предложено до %s", deps.formatTime(e.OfferExpiresAt, deadlineLayout))
+		case !e.OfferExpiresAt.IsZero():
+			text.WriteString(" — предложение истекло")
+		}
+		text.WriteString("\n")
+	}
+	sendLong(bot, chatID, text.String())
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"tgbot/models"
+	"tgbot/registry"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleWithdraw предлагает сняться с одной дисциплины или со всего турнира: /withdraw
+func HandleWithdraw(ctx context.Context, bot Bot, deps *Deps, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	userID := update.Message.From.ID
+
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы ещё не зарегистрированы."))
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading user %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+	if len(u.Disciplines) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы не участвуете ни в одной дисциплине."))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range registry.All() {
+		if _, ok := u.Disciplines[d.Name]; ok {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🚪 "+d.Name, "wd_"+d.Code),
+			))
+		}
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🚫 Сняться с турнира полностью", "wd_all")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("↩️ Отмена", "wd_cancel")),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, "С какой дисциплины вы хотите сняться?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+// handleWithdrawCallback обрабатывает кнопки снятия:
+// wd_<код>|wd_all — запрос подтверждения, wd_yes_<код>|wd_yes_all — снятие, wd_cancel — отмена
+func handleWithdrawCallback(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, data string) {
+	if data == "wd_cancel" {
+		bot.Send(tgbotapi.NewMessage(chatID, "Хорошо, вы остаётесь в турнире."))
+		return
+	}
+	if target, ok := strings.CutPrefix(data, "wd_yes_"); ok {
+		handleWithdrawConfirm(ctx, bot, deps, userID, chatID, target)
+		return
+	}
+
+	target := strings.TrimPrefix(data, "wd_")
+	question := "Вы уверены, что хотите сняться со всего турнира? Все ваши дисциплины будут отменены."
+	if target != "all" {
+		d, ok := registry.ByCode(target)
+		if !ok {
+			log.Printf("Unknown withdraw callback: %s from user %d", data, userID)
+			return
+		}
+		question = fmt.Sprintf("Вы уверены, что хотите сняться с %s?", d.Name)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, question)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, сняться", "wd_yes_"+target),
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Нет", "wd_cancel"),
+		),
+	)
+	bot.Send(msg)
+}
+
+// handleWithdrawConfirm снимает участника и уведомляет организаторов
+func handleWithdrawConfirm(ctx context.Context, bot Bot, deps *Deps, userID, chatID int64, target string) {
+	u, err := deps.Users.GetByTelegramID(ctx, userID)
+	if err != nil {
+		log.Printf("Error loading user %d: %v", userID, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при загрузке данных. Попробуйте позже."))
+		return
+	}
+
+	var games []string
+	if target == "all" {
+		for game := range u.Disciplines {
+			games = append(games, game)
+		}
+		err = deps.Users.Withdraw(ctx, userID)
+	} else {
+		d, ok := registry.ByCode(target)
+		if !ok {
+			log.Printf("Unknown withdraw target: %s from user %d", target, userID)
+			return
+		}
+		games = []string{d.Name}
+		err = deps.Users.WithdrawDiscipline(ctx, userID, d.Name)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Вы уже сняты с этой дисциплины."))
+		return
+	}
+	if err != nil {
+		log.Printf("Error withdrawing user %d from %s: %v", userID, target, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "❌ Ошибка при сохранении. Попробуйте позже."))
+		return
+	}
+
+	if target == "all" {
+		bot.Send(tgbotapi.NewMessage(chatID, "✅ Вы сняты с турнира. Чтобы зарегистрироваться снова, используйте /start"))
+	} else {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Вы сняты с %s.", games[0])))
+	}
+
+	notifyAdmin(bot, deps, fmt.Sprintf(
+		"🚪 Участник снялся с турнира\n%s\nДисциплины: %s\n\nПроверьте турнирные сетки (/bracket).",
+		formatUserShort(u), strings.Join(games, ", ")))
+
+	// Освободившиеся места предлагаем листу ожидания
+	freed := games
+	if target == "all" {
+		freed = append(freed, leaveWaitlists(ctx, deps, userID)...)
+	}
+	for _, game := range freed {
+		promoteWaitlist(ctx, bot, deps, game)
+	}
+}
+
+// formatUserShort форматирует участника одной строкой для сообщений организаторам
+func formatUserShort(u *models.User) string {
+	return fmt.Sprintf("%s %s, %s (tg_id %d)", u.FirstName, u.LastName, u.Class, u.TelegramID)
+}
@@ -1,26 +1,74 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	"tgbot/access"
 	"tgbot/config"
 	"tgbot/database"
+	"tgbot/dispatcher"
 	"tgbot/handlers"
 	"tgbot/models"
+	"tgbot/profiles"
+	"tgbot/registry"
+	"tgbot/scheduler"
+	"tgbot/sender"
 	"tgbot/states"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-const adminChatID = 6486655216
+// commandPermissions — какое право нужно для каждой команды организаторов.
+// Команд, которых здесь нет, могут вызывать все участники
+var commandPermissions = map[string]access.Permission{
+	"backup":       access.Backup,
+	"deadline":     access.Settings,
+	"registration": access.Settings,
+	"capacity":     access.Settings,
+	"waitlist":     access.Settings,
+	"result":       access.SetResults,
+	"bracket_gen":  access.ManageBracket,
+	"checkin":      access.ManageBracket,
+	"schedule":     access.ManageBracket,
+	"bracket":      access.ViewBracket,
+	"users":        access.ViewUsers,
+	"find":         access.ViewUsers,
+	"user":         access.ViewUsers,
+	"delete":       access.DeleteUsers,
+	"stats":        access.ViewUsers,
+	"conflicts":    access.ViewUsers,
+	"grant":        access.ManageRoles,
+	"revoke":       access.ManageRoles,
+	"admins":       access.ManageRoles,
+	"broadcast":    access.Broadcast,
+}
+
+// shutdownTimeout — сколько ждать завершения обработчиков и HTTP-сервера после SIGTERM
+// (Render даёт 30 секунд до SIGKILL)
+const shutdownTimeout = 20 * time.Second
+
+// jobsRetention — сколько хранить выполненные задачи, чтобы по ним можно было разобрать сбои
+const jobsRetention = 30 * 24 * time.Hour
+
+// backups отслеживает бэкапы, задачи планировщика и фоновые рассылки обработчиков,
+// чтобы не обрывать их при остановке
+var backups sync.WaitGroup
 
 func main() {
+	migrateDown := flag.Int("migrate-down", 0, "revert the given number of schema migrations and exit")
+	flag.Parse()
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("config load: %v", err)
@@ -32,67 +80,263 @@ func main() {
 		log.Fatal("database dsn not set (DATABASE_URL or DB_* env vars)")
 	}
 
-	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	reg, err := registry.Load(cfg.DisciplinesFile)
+	if err != nil {
+		log.Fatalf("disciplines load: %v", err)
+	}
+	registry.Use(reg)
+
+	// ctx отменяется по SIGINT/SIGTERM: прекращаем приём обновлений и фоновые задачи
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *migrateDown > 0 {
+		db, err := database.Connect(ctx, cfg.DBDSN)
+		if err != nil {
+			log.Fatalf("db connect: %v", err)
+		}
+		defer db.Close()
+		if err := database.Rollback(ctx, db, *migrateDown); err != nil {
+			log.Fatalf("rollback: %v", err)
+		}
+		return
+	}
+
+	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		log.Fatalf("bot init: %v", err)
 	}
+	// Все исходящие сообщения идут через ограничитель частоты с повторами
+	bot := sender.New(api, sender.DefaultOptions())
 
-	db, err := database.Open(cfg.DBDSN)
+	db, err := database.Open(ctx, cfg.DBDSN)
 	if err != nil {
 		log.Fatalf("db open: %v", err)
 	}
 	defer db.Close()
 
-	mgr := states.NewManager()
+	// Сессии регистрации хранятся в Postgres, чтобы переживать перезапуски
+	mgr := states.NewManagerWithStore(database.NewSessionStore(db))
+	jobs := database.NewJobRepository(db)
+	for _, id := range cfg.AdminIDs {
+		if err := database.EnsureOwner(ctx, db, id); err != nil {
+			log.Printf("bootstrap owner %d: %v", id, err)
+		}
+	}
+	if len(cfg.AdminIDs) == 0 {
+		log.Println("ADMIN_IDS is empty: only organizers already in the admins table can use admin commands")
+	}
+	deps := &handlers.Deps{
+		Users:      database.NewUserRepository(db),
+		Settings:   database.NewSettingsStore(db),
+		Waitlist:   database.NewWaitlistRepository(db),
+		Jobs:       jobs,
+		Matches:    database.NewMatchRepository(db),
+		Admins:     database.NewAdminRepository(db),
+		Broadcasts: database.NewBroadcastRepository(db),
+		Checkins:   database.NewCheckinRepository(db),
+		Verifiers: map[string]profiles.Verifier{
+			"chess.com": profiles.NewChessCom(5 * time.Second),
+		},
+		AdminChatID:        cfg.AdminChatID,
+		Owners:             cfg.AdminIDs,
+		Location:           cfg.Location,
+		SessionRemindAfter: cfg.SessionRemindAfter,
+		SessionTTL:         cfg.SessionTTL,
+		Background:         &backups,
+	}
 
-	// Запуск горутины для автоматического бэкапа каждые 30 минут
-	go startBackupRoutine(bot, db)
+	// workCtx живёт дольше ctx: обработчики, начатые до сигнала, успевают завершиться
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
+	// Отложенные задачи хранятся в таблице jobs и переживают перезапуски:
+	// бэкапы, лист ожидания, чек-ин и напоминания участникам
+	sched := scheduler.New(jobs, scheduler.DefaultOptions())
+	if err := handlers.RegisterJobs(ctx, sched, bot, deps, mgr); err != nil {
+		log.Fatalf("jobs: %v", err)
+	}
+	if err := scheduleMaintenance(ctx, sched, jobs, bot, db, cfg.AdminChatID); err != nil {
+		log.Fatalf("jobs: %v", err)
+	}
+	backups.Add(1)
+	go func() {
+		defer backups.Done()
+		sched.Run(workCtx, ctx.Done())
+	}()
+
+	// В режиме вебхука обновления приходят на тот же HTTP-сервер, что и health check
+	var updates tgbotapi.UpdatesChannel
+	var receiver *webhookReceiver
+	if cfg.WebhookURL != "" {
+		receiver = newWebhookReceiver(api, cfg.WebhookSecret)
+		http.Handle(webhookPath, receiver)
+		updates = receiver.Updates()
+	}
 
 	// Запуск HTTP-сервера для Render (чтобы не было ошибки Port scan timeout)
-	go startHealthCheckServer()
+	srv := startHealthCheckServer(cfg.Port)
 
-	ucfg := tgbotapi.NewUpdate(0)
-	ucfg.Timeout = 30
-	updates := bot.GetUpdatesChan(ucfg)
+	if cfg.WebhookURL != "" {
+		link, err := setWebhook(api, cfg.WebhookURL, cfg.WebhookSecret)
+		if err != nil {
+			log.Fatalf("webhook: %v", err)
+		}
+		log.Printf("Receiving updates via webhook %s", link)
+	} else {
+		// Локальная разработка: long polling
+		deleteWebhook(api)
+		ucfg := tgbotapi.NewUpdate(0)
+		// Короткий long poll, чтобы StopReceivingUpdates срабатывал быстро при остановке
+		ucfg.Timeout = 10
+		updates = api.GetUpdatesChan(ucfg)
+		log.Println("Receiving updates via long polling")
+	}
 
 	log.Println("Bot started successfully!")
 
-	for update := range updates {
-		if update.Message != nil {
-			if update.Message.IsCommand() {
-				switch update.Message.Command() {
-				case "start":
-					handlers.HandleStart(bot, mgr, update)
-				case "help":
-					bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Используйте /start для регистрации, /cancel для отмены, /mystats для просмотра данных."))
-				case "cancel":
-					mgr.Reset(update.Message.From.ID)
-					bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Регистрация отменена."))
-				case "backup":
-					if update.Message.Chat.ID == adminChatID {
-						go performBackup(bot, db)
-						bot.Send(tgbotapi.NewMessage(adminChatID, "⏳ Создаю бэкап..."))
-					}
-				default:
-					bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Неизвестная команда"))
-				}
-			} else {
-				handlers.HandleMessage(bot, db, mgr, update)
-			}
-		}
-		if update.CallbackQuery != nil {
-			handlers.HandleCallback(bot, db, mgr, update)
+	// Обновления обрабатываются параллельно, но по порядку для каждого пользователя
+	disp := dispatcher.New(cfg.Workers, cfg.QueueSize, func(update tgbotapi.Update) {
+		handleUpdate(workCtx, bot, db, deps, mgr, update)
+	})
+	disp.Start()
+
+	// На всю остановку после сигнала даётся shutdownTimeout: по его истечении
+	// shutdown отменяется, и обработчики с HTTP-сервером прерываются
+	shutdown, expire := context.WithCancel(context.Background())
+	defer expire()
+
+	// По сигналу перестаём получать обновления; канал закроется и цикл ниже завершится
+	go func() {
+		<-ctx.Done()
+		time.AfterFunc(shutdownTimeout, expire)
+		log.Println("Shutting down: no longer receiving updates")
+		if receiver != nil {
+			receiver.Close()
+		} else {
+			api.StopReceivingUpdates()
 		}
+	}()
+
+	for update := range updates {
+		disp.Dispatch(update)
+	}
+
+	// Дожидаемся обработки уже полученных обновлений и бэкапа в процессе
+	done := make(chan struct{})
+	go func() {
+		disp.Stop()
+		backups.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("All updates handled")
+	case <-shutdown.Done():
+		log.Println("Shutdown timeout: aborting in-flight handlers")
+		cancelWork()
 	}
+
+	shutdownServer(shutdown, srv)
+	log.Println("Bot stopped")
 }
 
-// startHealthCheckServer запускает простой HTTP-сервер для health checks
-func startHealthCheckServer() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "10000" // Render использует порт 10000 по умолчанию
+// shutdownServer останавливает HTTP-сервер, давая активным запросам время до отмены ctx
+func shutdownServer(ctx context.Context, srv *http.Server) {
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
 	}
+}
+
+// handleUpdate направляет обновление нужному обработчику
+func handleUpdate(ctx context.Context, bot *sender.Sender, db *sql.DB, deps *handlers.Deps, mgr *states.Manager, update tgbotapi.Update) {
+	if update.Message != nil {
+		if update.Message.IsCommand() {
+			cmd := update.Message.Command()
+			if perm, ok := commandPermissions[cmd]; ok && !handlers.Can(ctx, deps, update.Message.From.ID, perm) {
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Неизвестная команда"))
+				return
+			}
 
+			switch cmd {
+			case "start":
+				handlers.HandleStart(ctx, bot, deps, mgr, update)
+			case "help":
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Используйте /start для регистрации, /cancel для отмены, /mystats для просмотра данных, /edit для изменения анкеты, /withdraw для снятия с турнира, /report для отправки результата матча."))
+			case "cancel":
+				mgr.Reset(update.Message.From.ID)
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Регистрация отменена."))
+			case "edit":
+				handlers.HandleEdit(ctx, bot, deps, mgr, update)
+			case "withdraw":
+				handlers.HandleWithdraw(ctx, bot, deps, update)
+			case "mystats":
+				handlers.HandleMyStats(ctx, bot, deps, update)
+			case "report":
+				handlers.HandleReport(ctx, bot, deps, update)
+
+			// Команды организаторов
+			case "backup":
+				backups.Add(1)
+				go func() {
+					defer backups.Done()
+					performBackup(ctx, bot, db, update.Message.Chat.ID)
+				}()
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "⏳ Создаю бэкап..."))
+			case "registration":
+				handlers.HandleRegistration(ctx, bot, deps, update)
+			case "capacity":
+				handlers.HandleCapacity(ctx, bot, deps, update)
+			case "waitlist":
+				handlers.HandleWaitlist(ctx, bot, deps, update)
+			case "deadline":
+				handlers.HandleDeadline(ctx, bot, deps, update)
+			case "result":
+				handlers.HandleSetResult(ctx, bot, deps, update)
+			case "bracket_gen":
+				handlers.HandleBracketGenerate(ctx, bot, deps, update)
+			case "bracket":
+				handlers.HandleBracketView(ctx, bot, deps, update)
+			case "checkin":
+				handlers.HandleCheckin(ctx, bot, deps, update)
+			case "schedule":
+				handlers.HandleMatchSchedule(ctx, bot, deps, update)
+			case "users":
+				handlers.HandleUsers(ctx, bot, deps, update)
+			case "find":
+				handlers.HandleFind(ctx, bot, deps, update)
+			case "user":
+				handlers.HandleUserCard(ctx, bot, deps, update)
+			case "delete":
+				handlers.HandleDeleteUser(ctx, bot, deps, update)
+			case "stats":
+				handlers.HandleAdminStats(ctx, bot, deps, update)
+			case "conflicts":
+				handlers.HandleConflicts(ctx, bot, deps, update)
+			case "broadcast":
+				handlers.HandleBroadcast(ctx, bot, deps, mgr, update)
+
+			// Управление ролями (только владелец)
+			case "grant":
+				handlers.HandleGrant(ctx, bot, deps, update)
+			case "revoke":
+				handlers.HandleRevoke(ctx, bot, deps, update)
+			case "admins":
+				handlers.HandleAdmins(ctx, bot, deps, update)
+			default:
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Неизвестная команда"))
+			}
+		} else {
+			handlers.HandleMessage(ctx, bot, deps, mgr, update)
+		}
+	}
+	if update.CallbackQuery != nil {
+		handlers.HandleCallback(ctx, bot, deps, mgr, update)
+	}
+}
+
+// startHealthCheckServer запускает HTTP-сервер для health checks (и вебхука, если он зарегистрирован)
+func startHealthCheckServer(port string) *http.Server {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "eTriathlon Bot is running! ✅")
@@ -103,41 +347,62 @@ func startHealthCheckServer() {
 		fmt.Fprintf(w, `{"status":"ok","timestamp":"%s"}`, time.Now().Format(time.RFC3339))
 	})
 
-	log.Printf("Health check server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Printf("Health check server error: %v", err)
-	}
+	srv := &http.Server{Addr: ":" + port}
+	go func() {
+		log.Printf("Health check server starting on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Health check server error: %v", err)
+		}
+	}()
+	return srv
 }
 
-// startBackupRoutine запускает периодический бэкап каждые 30 минут
-func startBackupRoutine(bot *tgbotapi.BotAPI, db *sql.DB) {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
-
-	msg := tgbotapi.NewMessage(adminChatID, "✅ Система автоматического бэкапа запущена\n⏰ Интервал: каждые 30 минут")
-	bot.Send(msg)
+// scheduleMaintenance заводит периодические служебные задачи: бэкап каждые
+// 30 минут и ежедневную очистку выполненных задач
+func scheduleMaintenance(ctx context.Context, sched *scheduler.Scheduler, jobs *database.JobRepository, bot *sender.Sender, db *sql.DB, chatID int64) error {
+	err := sched.Every(ctx, "purge_jobs", 24*time.Hour, func(ctx context.Context, job models.Job) error {
+		n, err := jobs.Purge(ctx, time.Now().Add(-jobsRetention))
+		if err == nil && n > 0 {
+			log.Printf("Purged %d finished jobs", n)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-	for range ticker.C {
-		performBackup(bot, db)
+	if chatID == 0 {
+		log.Println("ADMIN_CHAT_ID is not set: automatic backups are disabled")
+		return jobs.Cancel(ctx, "backup")
 	}
+	err = sched.Every(ctx, "backup", 30*time.Minute, func(ctx context.Context, job models.Job) error {
+		performBackup(ctx, bot, db, chatID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, "✅ Система автоматического бэкапа запущена\n⏰ Интервал: каждые 30 минут"))
+	return nil
 }
 
-func performBackup(bot *tgbotapi.BotAPI, db *sql.DB) {
+// performBackup выгружает базу в CSV и отправляет файл в chatID
+func performBackup(ctx context.Context, bot *sender.Sender, db *sql.DB, chatID int64) {
 	filename := fmt.Sprintf("backup_etriathlon_%s.csv", time.Now().Format("2006-01-02_15-04-05"))
 
-	err := exportToCSV(db, filename)
+	err := exportToCSV(ctx, db, filename)
 	if err != nil {
 		log.Printf("Ошибка создания бэкапа: %v", err)
-		msg := tgbotapi.NewMessage(adminChatID, fmt.Sprintf("❌ Ошибка создания бэкапа: %v", err))
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка создания бэкапа: %v", err))
 		bot.Send(msg)
 		return
 	}
 	defer os.Remove(filename)
 
-	err = sendBackupFile(bot, filename)
+	err = sendBackupFile(bot, chatID, filename)
 	if err != nil {
 		log.Printf("Ошибка отправки бэкапа: %v", err)
-		msg := tgbotapi.NewMessage(adminChatID, fmt.Sprintf("❌ Ошибка отправки бэкапа: %v", err))
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка отправки бэкапа: %v", err))
 		bot.Send(msg)
 		return
 	}
@@ -145,7 +410,7 @@ func performBackup(bot *tgbotapi.BotAPI, db *sql.DB) {
 	log.Printf("Бэкап успешно отправлен: %s", filename)
 }
 
-func exportToCSV(db *sql.DB, filename string) error {
+func exportToCSV(ctx context.Context, db *sql.DB, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("create file: %w", err)
@@ -161,53 +426,33 @@ func exportToCSV(db *sql.DB, filename string) error {
 
 	writer.Write([]string{"=== TABLE: users ==="})
 
-	rows, err := db.Query("SELECT id, tg_id, first_name, last_name, class, disciplines FROM users ORDER BY id")
+	users := database.NewUserRepository(db)
+	all, total, err := users.List(ctx, database.UserFilter{}, 0, 0)
 	if err != nil {
 		return fmt.Errorf("query users: %w", err)
 	}
-	defer rows.Close()
-
-	writer.Write([]string{"ID", "Telegram ID", "Имя", "Фамилия", "Класс", "Дисциплины"})
 
-	rowCount := 0
-	for rows.Next() {
-		var id int64
-		var tgID int64
-		var firstName, lastName, class string
-		var disciplinesJSON []byte
-
-		err := rows.Scan(&id, &tgID, &firstName, &lastName, &class, &disciplinesJSON)
-		if err != nil {
-			log.Printf("Ошибка сканирования строки: %v", err)
-			continue
-		}
-
-		var disciplines map[string]models.GameData
-		disciplinesStr := string(disciplinesJSON)
-		if len(disciplinesJSON) > 0 {
-			if err := json.Unmarshal(disciplinesJSON, &disciplines); err == nil {
-				disciplinesStr = formatDisciplines(disciplines)
-			}
-		}
+	writer.Write([]string{"ID", "Telegram ID", "Имя", "Фамилия", "Класс", "Дисциплины", "Статус"})
 
+	for _, u := range all {
 		row := []string{
-			fmt.Sprintf("%d", id),
-			fmt.Sprintf("%d", tgID),
-			firstName,
-			lastName,
-			class,
-			disciplinesStr,
+			fmt.Sprintf("%d", u.ID),
+			fmt.Sprintf("%d", u.TelegramID),
+			u.FirstName,
+			u.LastName,
+			u.Class,
+			formatDisciplines(u.Disciplines),
+			u.Status,
 		}
 		writer.Write(row)
-		rowCount++
 	}
 
 	writer.Write([]string{})
-	writer.Write([]string{fmt.Sprintf("Total registrations: %d", rowCount)})
+	writer.Write([]string{fmt.Sprintf("Total registrations: %d", total)})
 	writer.Write([]string{})
 
 	writer.Write([]string{"=== STATISTICS BY DISCIPLINE ==="})
-	stats, err := getStatistics(db)
+	stats, err := users.CountByDiscipline(ctx)
 	if err == nil {
 		for discipline, count := range stats {
 			writer.Write([]string{discipline, fmt.Sprintf("%d участников", count)})
@@ -223,7 +468,7 @@ func formatDisciplines(disciplines map[string]models.GameData) string {
 		if result != "" {
 			result += "; "
 		}
-		if game == "Chess" {
+		if d, ok := registry.ByName(game); (ok && !d.NeedsTag()) || data.Tag == "" {
 			result += fmt.Sprintf("%s: %s", game, data.Nick)
 		} else {
 			result += fmt.Sprintf("%s: %s %s", game, data.Nick, data.Tag)
@@ -232,36 +477,8 @@ func formatDisciplines(disciplines map[string]models.GameData) string {
 	return result
 }
 
-func getStatistics(db *sql.DB) (map[string]int, error) {
-	stats := make(map[string]int)
-
-	rows, err := db.Query("SELECT disciplines FROM users")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var disciplinesJSON []byte
-		if err := rows.Scan(&disciplinesJSON); err != nil {
-			continue
-		}
-
-		var disciplines map[string]models.GameData
-		if err := json.Unmarshal(disciplinesJSON, &disciplines); err != nil {
-			continue
-		}
-
-		for game := range disciplines {
-			stats[game]++
-		}
-	}
-
-	return stats, nil
-}
-
-func sendBackupFile(bot *tgbotapi.BotAPI, filename string) error {
-	file := tgbotapi.NewDocument(adminChatID, tgbotapi.FilePath(filename))
+func sendBackupFile(bot *sender.Sender, chatID int64, filename string) error {
+	file := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filename))
 
 	fileInfo, _ := os.Stat(filename)
 	fileSize := float64(fileInfo.Size()) / 1024
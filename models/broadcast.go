@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Типы содержимого рассылки
+const (
+	BroadcastText     = "text"
+	BroadcastPhoto    = "photo"
+	BroadcastDocument = "document"
+)
+
+// Аудитория рассылки
+const (
+	TargetAll        = "all"
+	TargetDiscipline = "discipline" // TargetValue — название дисциплины
+	TargetClass      = "class"      // TargetValue — класс
+)
+
+// Статусы доставки одному получателю
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryBlocked = "blocked" // пользователь заблокировал бота
+	DeliveryFailed  = "failed"
+)
+
+// Broadcast is an announcement sent by an organizer to a group of participants
+type Broadcast struct {
+	ID          int64     `json:"id"`
+	AuthorID    int64     `json:"author_id"`
+	Target      string    `json:"target"`
+	TargetValue string    `json:"target_value"`
+	Kind        string    `json:"kind"`
+	Text        string    `json:"text"`    // текст сообщения или подпись к файлу
+	FileID      string    `json:"file_id"` // file_id фото или документа в Telegram
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// CheckinWindow is the period when participants of a discipline confirm that
+// they will play. Once a window has been opened, the bracket is built from
+// checked-in players only.
+type CheckinWindow struct {
+	Discipline  string        `json:"discipline"`
+	OpensAt     time.Time     `json:"opens_at"`
+	ClosesAt    time.Time     `json:"closes_at"`
+	RemindEvery time.Duration `json:"remind_every"` // 0 — без напоминаний
+	RemindedAt  time.Time     `json:"reminded_at"`  // последнее приглашение или напоминание
+}
+
+// Open reports whether participants can check in at now
+func (w CheckinWindow) Open(now time.Time) bool {
+	return !now.Before(w.OpensAt) && now.Before(w.ClosesAt)
+}
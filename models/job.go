@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job is a task kept in the jobs table so that it survives restarts. A one-shot
+// job runs once at RunAt; a recurring one (Every > 0) is moved Every forward
+// after each run.
+type Job struct {
+	ID        int64           `json:"id"`
+	Key       string          `json:"key"`  // уникальное имя задачи, чтобы её можно было перенести или отменить; пустое — анонимная задача
+	Kind      string          `json:"kind"` // какой обработчик её выполняет
+	Payload   json.RawMessage `json:"payload"`
+	RunAt     time.Time       `json:"run_at"`
+	Every     time.Duration   `json:"every"`
+	Attempts  int             `json:"attempts"` // неудачных запусков подряд, включая текущий
+	LastError string          `json:"last_error"`
+}
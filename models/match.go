@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Этапы турнира
+const (
+	StageGroup   = "group"
+	StagePlayoff = "playoff"
+)
+
+// Статусы матча
+const (
+	MatchPending  = "pending"
+	MatchReported = "reported" // результат отправлен, ждёт подтверждения соперника
+	MatchDisputed = "disputed" // соперник оспорил результат, решает админ
+	MatchDone     = "done"
+)
+
+// Match is a single game between two participants. Players are identified by
+// Telegram ID, 0 means the slot is not decided yet (or is a bye).
+type Match struct {
+	ID         int64     `json:"id"`
+	Discipline string    `json:"discipline"`
+	Stage      string    `json:"stage"`
+	GroupName  string    `json:"group_name"`
+	Round      int       `json:"round"`
+	Slot       int       `json:"slot"`
+	Source1    string    `json:"source1"`
+	Source2    string    `json:"source2"`
+	Player1    int64     `json:"player1"`
+	Player2    int64     `json:"player2"`
+	Score1     int       `json:"score1"`
+	Score2     int       `json:"score2"`
+	Winner     int64     `json:"winner"`
+	Status     string    `json:"status"`
+	ReportedBy int64     `json:"reported_by"`
+	StartsAt   time.Time `json:"starts_at"` // нулевое — время матча не назначено
+}
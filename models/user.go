@@ -1,5 +1,13 @@
 package models
 
+import "time"
+
+// Статусы участника
+const (
+	UserActive    = "active"
+	UserWithdrawn = "withdrawn" // снялся со всех дисциплин, запись сохранена
+)
+
 type GameData struct {
 	Nick string `json:"nick"`
 	Tag  string `json:"tag"`
@@ -12,4 +20,14 @@ type User struct {
 	LastName    string              `json:"last_name"`
 	Class       string              `json:"class"`
 	Disciplines map[string]GameData `json:"disciplines"`
+	Status      string              `json:"status"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// Conflict is a nick or tag that several participants entered for the same game
+type Conflict struct {
+	Discipline  string  `json:"discipline"`
+	Field       string  `json:"field"` // nick или tag
+	Value       string  `json:"value"`
+	TelegramIDs []int64 `json:"telegram_ids"`
 }
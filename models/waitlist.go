@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// WaitlistEntry is a participant waiting for a spot in a full discipline.
+// Data holds the nick and tag entered at registration, so that the spot can
+// be given without asking again.
+type WaitlistEntry struct {
+	Discipline     string    `json:"discipline"`
+	TelegramID     int64     `json:"telegram_id"`
+	Data           GameData  `json:"data"`
+	Position       int       `json:"position"`         // место в очереди, с 1
+	OfferExpiresAt time.Time `json:"offer_expires_at"` // до какого момента участник может занять место; нулевое — место не предлагалось
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Offered reports whether the participant holds an unexpired offer of a spot
+func (e WaitlistEntry) Offered(now time.Time) bool {
+	return !e.OfferExpiresAt.IsZero() && now.Before(e.OfferExpiresAt)
+}
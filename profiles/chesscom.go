@@ -0,0 +1,82 @@
+package profiles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChessComBaseURL is the public API of Chess.com
+const ChessComBaseURL = "https://api.chess.com/pub"
+
+// ChessCom looks up accounts through the public Chess.com API
+type ChessCom struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewChessCom returns a client with the given request timeout
+func NewChessCom(timeout time.Duration) *ChessCom {
+	return &ChessCom{client: &http.Client{Timeout: timeout}, baseURL: ChessComBaseURL}
+}
+
+func (c *ChessCom) Lookup(ctx context.Context, username string) (*Profile, error) {
+	name := url.PathEscape(strings.ToLower(username))
+
+	var player struct {
+		Username string `json:"username"`
+		URL      string `json:"url"`
+	}
+	if err := c.get(ctx, "/player/"+name, &player); err != nil {
+		return nil, err
+	}
+
+	p := &Profile{Username: player.Username, URL: player.URL, RatingOf: "рапид"}
+	if p.Username == "" {
+		p.Username = username
+	}
+
+	// Рейтинг не обязателен: если статистика недоступна, возвращаем профиль без него
+	var stats struct {
+		Rapid struct {
+			Last struct {
+				Rating int `json:"rating"`
+			} `json:"last"`
+		} `json:"chess_rapid"`
+	}
+	if err := c.get(ctx, "/player/"+name+"/stats", &stats); err == nil {
+		p.Rating = stats.Rapid.Last.Rating
+	}
+	return p, nil
+}
+
+func (c *ChessCom) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	// Chess.com просит указывать контакт в User-Agent
+	req.Header.Set("User-Agent", "eTriathlon registration bot")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chess.com: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("chess.com: unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("chess.com: decode: %w", err)
+	}
+	return nil
+}
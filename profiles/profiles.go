@@ -0,0 +1,61 @@
+// Package profiles checks that a game account entered during registration exists.
+package profiles
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Profile is a public game account
+type Profile struct {
+	Username string // ник в том регистре, в котором он хранится на платформе
+	URL      string
+	Rating   int    // рейтинг игрока; 0 — рейтинга ещё нет
+	RatingOf string // за что рейтинг, например «рапид»; пусто — платформа рейтинг не сообщает
+}
+
+// ErrNotFound is returned when the platform has no account with this username
+var ErrNotFound = errors.New("profiles: account not found")
+
+// Verifier looks up an account by username. Any error other than ErrNotFound
+// means the service could not be reached and the nick should be accepted manually.
+type Verifier interface {
+	Lookup(ctx context.Context, username string) (*Profile, error)
+}
+
+// Fake is an in-memory Verifier for local development and tests
+type Fake struct {
+	mu       sync.RWMutex
+	profiles map[string]Profile
+	Err      error // если задано, Lookup всегда возвращает эту ошибку
+}
+
+func NewFake(profiles ...Profile) *Fake {
+	f := &Fake{profiles: make(map[string]Profile)}
+	for _, p := range profiles {
+		f.Add(p)
+	}
+	return f
+}
+
+// Add registers an account in the fake
+func (f *Fake) Add(p Profile) {
+	f.mu.Lock()
+	f.profiles[strings.ToLower(p.Username)] = p
+	f.mu.Unlock()
+}
+
+func (f *Fake) Lookup(ctx context.Context, username string) (*Profile, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	p, ok := f.profiles[strings.ToLower(username)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
@@ -0,0 +1,215 @@
+// Package registry holds the list of tournament disciplines. It is loaded from a
+// JSON file so that a new game can be added without changing the handlers.
+package registry
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Поля, которые участник вводит для дисциплины
+const (
+	FieldNick = "nick"
+	FieldTag  = "tag"
+)
+
+// defaultTagRule is used when a game needs a tag but has no tag rule
+var defaultTagRule = Rule{Prefix: "#", Pattern: `^\w+$`, Example: "#ABC123"}
+
+//go:embed default.json
+var defaultJSON []byte
+
+// reserved are callback suffixes already taken by the triathlon keyboard (tri_check, tri_done...)
+var reserved = map[string]bool{"tri": true, "check": true, "done": true, "confirm": true, "all": true, "cancel": true, "yes": true}
+
+var codeRe = regexp.MustCompile(`^[a-z0-9]{1,16}$`)
+
+// Discipline describes one game of the tournament
+type Discipline struct {
+	Code       string   `json:"code"`        // короткий код для callback data: bs, cr, ch
+	Name       string   `json:"name"`        // название, оно же ключ в users.disciplines
+	Rules      string   `json:"rules"`       // текст правил, показывается перед вводом ника
+	Fields     []string `json:"fields"`      // обязательные поля: nick, tag
+	Nick       Rule     `json:"nick"`        // проверка ника
+	Tag        Rule     `json:"tag"`         // проверка тега
+	TeamSize   int      `json:"team_size"`   // игроков в команде
+	WinsNeeded int      `json:"wins_needed"` // побед для выигрыша матча
+	GroupDraws bool     `json:"group_draws"` // разрешена ли ничья на групповом этапе
+	Triathlon  bool     `json:"triathlon"`   // входит ли игра в триатлон
+	Verify     string   `json:"verify"`      // платформа для проверки аккаунта по нику: chess.com
+}
+
+// NeedsTag reports whether the participant must enter a player tag
+func (d Discipline) NeedsTag() bool {
+	for _, f := range d.Fields {
+		if f == FieldTag {
+			return true
+		}
+	}
+	return false
+}
+
+// IDField returns the field that identifies the player in the game: the tag
+// if the game has one (nicks there are not unique), otherwise the nick
+func (d Discipline) IDField() string {
+	if d.NeedsTag() {
+		return FieldTag
+	}
+	return FieldNick
+}
+
+// NormalizeNick checks the nick by the rules of the discipline and returns it normalized
+func (d Discipline) NormalizeNick(nick string) (string, error) {
+	return d.Nick.Normalize(nick)
+}
+
+// NormalizeTag checks the tag by the rules of the discipline and returns it
+// normalized (for example uppercased and with the # prefix)
+func (d Discipline) NormalizeTag(tag string) (string, error) {
+	return d.Tag.Normalize(tag)
+}
+
+// Registry is an ordered set of disciplines
+type Registry struct {
+	list   []Discipline
+	byCode map[string]int
+	byName map[string]int
+}
+
+// Parse builds a registry from JSON and validates it
+func Parse(data []byte) (*Registry, error) {
+	var list []Discipline
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("registry: list is empty")
+	}
+
+	r := &Registry{byCode: make(map[string]int), byName: make(map[string]int)}
+	for i := range list {
+		d := &list[i]
+		if !codeRe.MatchString(d.Code) || reserved[d.Code] {
+			return nil, fmt.Errorf("registry: bad code %q", d.Code)
+		}
+		if d.Name == "" {
+			return nil, fmt.Errorf("registry: %s has no name", d.Code)
+		}
+		if _, dup := r.byCode[d.Code]; dup {
+			return nil, fmt.Errorf("registry: duplicate code %q", d.Code)
+		}
+		if _, dup := r.byName[d.Name]; dup {
+			return nil, fmt.Errorf("registry: duplicate name %q", d.Name)
+		}
+		if len(d.Fields) == 0 {
+			d.Fields = []string{FieldNick}
+		}
+		if d.TeamSize < 1 {
+			d.TeamSize = 1
+		}
+		if d.WinsNeeded < 1 {
+			d.WinsNeeded = 1
+		}
+		if d.NeedsTag() && d.Tag.Pattern == "" && d.Tag.Alphabet == "" {
+			d.Tag = defaultTagRule
+		}
+		if err := d.Nick.compile(); err != nil {
+			return nil, fmt.Errorf("registry: %s nick pattern: %w", d.Code, err)
+		}
+		if err := d.Tag.compile(); err != nil {
+			return nil, fmt.Errorf("registry: %s tag pattern: %w", d.Code, err)
+		}
+		r.byCode[d.Code] = i
+		r.byName[d.Name] = i
+	}
+	r.list = list
+	return r, nil
+}
+
+// Load reads the registry from a JSON file; an empty path gives the built-in list
+func Load(path string) (*Registry, error) {
+	if path == "" {
+		return Parse(defaultJSON)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+	return Parse(data)
+}
+
+// All returns disciplines in configured order
+func (r *Registry) All() []Discipline {
+	return append([]Discipline(nil), r.list...)
+}
+
+// ByCode finds a discipline by its short code
+func (r *Registry) ByCode(code string) (Discipline, bool) {
+	i, ok := r.byCode[code]
+	if !ok {
+		return Discipline{}, false
+	}
+	return r.list[i], true
+}
+
+// ByName finds a discipline by its name (the key in users.disciplines)
+func (r *Registry) ByName(name string) (Discipline, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return Discipline{}, false
+	}
+	return r.list[i], true
+}
+
+// Triathlon returns the disciplines that make up the triathlon
+func (r *Registry) Triathlon() []Discipline {
+	var tri []Discipline
+	for _, d := range r.list {
+		if d.Triathlon {
+			tri = append(tri, d)
+		}
+	}
+	return tri
+}
+
+var (
+	mu      sync.RWMutex
+	current = mustParse(defaultJSON)
+)
+
+func mustParse(data []byte) *Registry {
+	r, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Use replaces the registry used by the package-level functions
+func Use(r *Registry) {
+	mu.Lock()
+	current = r
+	mu.Unlock()
+}
+
+func get() *Registry {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// All returns disciplines of the current registry
+func All() []Discipline { return get().All() }
+
+// ByCode finds a discipline of the current registry by code
+func ByCode(code string) (Discipline, bool) { return get().ByCode(code) }
+
+// ByName finds a discipline of the current registry by name
+func ByName(name string) (Discipline, bool) { return get().ByName(name) }
+
+// Triathlon returns triathlon disciplines of the current registry
+func Triathlon() []Discipline { return get().Triathlon() }
@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "not json", json: `{`, wantErr: "registry:"},
+		{name: "empty list", json: `[]`, wantErr: "list is empty"},
+		{name: "bad code", json: `[{"code": "B S", "name": "Brawl Stars"}]`, wantErr: `bad code "B S"`},
+		{name: "reserved code", json: `[{"code": "done", "name": "Done"}]`, wantErr: `bad code "done"`},
+		{name: "no name", json: `[{"code": "bs"}]`, wantErr: "bs has no name"},
+		{name: "duplicate code", json: `[{"code": "bs", "name": "A"}, {"code": "bs", "name": "B"}]`, wantErr: `duplicate code "bs"`},
+		{name: "duplicate name", json: `[{"code": "a", "name": "Chess"}, {"code": "b", "name": "Chess"}]`, wantErr: `duplicate name "Chess"`},
+		{name: "bad pattern", json: `[{"code": "ch", "name": "Chess", "nick": {"pattern": "("}}]`, wantErr: "ch nick pattern"},
+		{name: "valid", json: `[{"code": "bs", "name": "Brawl Stars", "fields": ["nick", "tag"]}, {"code": "ch", "name": "Chess"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.json))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	r, err := Parse([]byte(`[
+		{"code": "bs", "name": "Brawl Stars", "fields": ["nick", "tag"], "triathlon": true},
+		{"code": "ch", "name": "Chess", "wins_needed": 2, "team_size": 3}
+	]`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		code       string
+		idField    string
+		teamSize   int
+		winsNeeded int
+		tagPrefix  string
+	}{
+		{code: "bs", idField: FieldTag, teamSize: 1, winsNeeded: 1, tagPrefix: "#"},
+		{code: "ch", idField: FieldNick, teamSize: 3, winsNeeded: 2},
+	}
+	for _, tt := range tests {
+		d, ok := r.ByCode(tt.code)
+		if !ok {
+			t.Fatalf("ByCode(%q) not found", tt.code)
+		}
+		if d.IDField() != tt.idField || d.TeamSize != tt.teamSize || d.WinsNeeded != tt.winsNeeded || d.Tag.Prefix != tt.tagPrefix {
+			t.Errorf("%s = %+v, want id field %s, team %d, wins %d, tag prefix %q",
+				tt.code, d, tt.idField, tt.teamSize, tt.winsNeeded, tt.tagPrefix)
+		}
+		if byName, ok := r.ByName(d.Name); !ok || byName.Code != tt.code {
+			t.Errorf("ByName(%q) = %+v, %v", d.Name, byName, ok)
+		}
+	}
+
+	// Тег без правил проверяется правилом по умолчанию
+	if got, err := r.list[0].NormalizeTag("abc123"); err != nil || got != "#abc123" {
+		t.Errorf("default tag rule: NormalizeTag = %q, %v; want #abc123", got, err)
+	}
+	if _, err := r.list[0].NormalizeTag("#abc-123"); err == nil {
+		t.Errorf("default tag rule accepted #abc-123")
+	}
+	if tri := r.Triathlon(); len(tri) != 1 || tri[0].Code != "bs" {
+		t.Errorf("Triathlon() = %+v, want only bs", tri)
+	}
+}
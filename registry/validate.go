@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError explains to the participant why the value was rejected
+type ValidationError struct {
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+func invalid(format string, args ...any) error {
+	return &ValidationError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// Rule describes how a nick or a tag of a discipline is normalized and checked
+type Rule struct {
+	Prefix    string            `json:"prefix"`    // обязательный префикс, добавляется автоматически: #
+	Uppercase bool              `json:"uppercase"` // переводить в верхний регистр
+	Alphabet  string            `json:"alphabet"`  // допустимые символы (после префикса); пусто — любые
+	Pattern   string            `json:"pattern"`   // регулярное выражение для значения без префикса
+	MinLen    int               `json:"min_len"`   // длина без префикса, в символах
+	MaxLen    int               `json:"max_len"`
+	Hints     map[string]string `json:"hints"`   // частые опечатки: "O" → "0"
+	Example   string            `json:"example"` // пример правильного значения для сообщений
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) compile() error {
+	if r.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// Normalize trims, uppercases and prefixes the value, then checks length,
+// alphabet and pattern. Inner spaces are rejected only when the rule has an
+// alphabet or a pattern. The error is a *ValidationError with a message for the user.
+func (r Rule) Normalize(input string) (string, error) {
+	value := strings.TrimSpace(input)
+	if r.Uppercase {
+		value = strings.ToUpper(value)
+	}
+	if r.Prefix != "" {
+		value = strings.TrimPrefix(value, r.Prefix)
+	}
+
+	if value == "" {
+		return "", invalid("Значение не может быть пустым.%s", r.example())
+	}
+	// Пробелы мешают только строгим форматам вроде тегов; в никах они допустимы
+	if (r.Alphabet != "" || r.Pattern != "") && strings.ContainsAny(value, " \t\n") {
+		return "", invalid("Уберите пробелы.%s", r.example())
+	}
+
+	n := utf8.RuneCountInString(value)
+	if r.MinLen > 0 && n < r.MinLen {
+		return "", invalid("Слишком коротко: нужно не меньше %d символов.%s", r.MinLen, r.example())
+	}
+	if r.MaxLen > 0 && n > r.MaxLen {
+		return "", invalid("Слишком длинно: допускается не больше %d символов.%s", r.MaxLen, r.example())
+	}
+
+	if r.Alphabet != "" {
+		for _, c := range value {
+			if strings.ContainsRune(r.Alphabet, c) {
+				continue
+			}
+			if fix, ok := r.Hints[string(c)]; ok {
+				return "", invalid("Символ «%c» здесь не используется — возможно, вы имели в виду «%s»?%s", c, fix, r.example())
+			}
+			return "", invalid("Недопустимый символ «%c». Разрешены только: %s.%s", c, r.Alphabet, r.example())
+		}
+	}
+
+	if r.re != nil && !r.re.MatchString(value) {
+		return "", invalid("Неверный формат.%s", r.example())
+	}
+	return r.Prefix + value, nil
+}
+
+func (r Rule) example() string {
+	if r.Example == "" {
+		return ""
+	}
+	return " Пример: " + r.Example
+}
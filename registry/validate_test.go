@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRuleNormalize(t *testing.T) {
+	tag := Rule{Prefix: "#", Uppercase: true, Alphabet: "0289PYLQGRJCUV", MinLen: 3, MaxLen: 14,
+		Hints: map[string]string{"O": "0"}, Example: "#2PQ8LJY0"}
+	nick := Rule{MinLen: 1, MaxLen: 15}
+	chess := Rule{Pattern: `^[A-Za-z0-9][A-Za-z0-9_-]*$`, MinLen: 3, MaxLen: 25}
+	for _, r := range []*Rule{&tag, &nick, &chess} {
+		if err := r.compile(); err != nil {
+			t.Fatalf("compile %q: %v", r.Pattern, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		input   string
+		want    string
+		wantErr string // часть сообщения об ошибке
+	}{
+		{name: "tag prefixed and uppercased", rule: tag, input: " 2pq8ljy0 ", want: "#2PQ8LJY0"},
+		{name: "tag prefix kept once", rule: tag, input: "#2PQ8LJY0", want: "#2PQ8LJY0"},
+		{name: "tag typo hint", rule: tag, input: "#2PQ8LJYO", wantErr: "«0»"},
+		{name: "tag bad symbol", rule: tag, input: "#2PQ8LJYX", wantErr: "Недопустимый символ «X»"},
+		{name: "tag too short", rule: tag, input: "#29", wantErr: "не меньше 3"},
+		{name: "tag too long", rule: tag, input: "#" + strings.Repeat("2", 15), wantErr: "не больше 14"},
+		{name: "tag with spaces", rule: tag, input: "#2PQ 8LJY0", wantErr: "Уберите пробелы"},
+		{name: "only prefix", rule: tag, input: "#", wantErr: "не может быть пустым"},
+		{name: "example in message", rule: tag, input: "", wantErr: "Пример: #2PQ8LJY0"},
+		{name: "nick keeps case and spaces", rule: nick, input: "  Big Boss ", want: "Big Boss"},
+		{name: "nick length in runes", rule: nick, input: strings.Repeat("я", 15), want: strings.Repeat("я", 15)},
+		{name: "nick too long", rule: nick, input: strings.Repeat("я", 16), wantErr: "не больше 15"},
+		{name: "pattern match", rule: chess, input: "magnus_2010", want: "magnus_2010"},
+		{name: "pattern mismatch", rule: chess, input: "_magnus", wantErr: "Неверный формат"},
+		{name: "empty rule accepts anything", rule: Rule{}, input: "x y", want: "x y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Normalize(tt.input)
+			if tt.wantErr != "" {
+				var ve *ValidationError
+				if !errors.As(err, &ve) {
+					t.Fatalf("Normalize(%q) = %q, %v; want a *ValidationError", tt.input, got, err)
+				}
+				if !strings.Contains(ve.Msg, tt.wantErr) {
+					t.Errorf("error %q does not mention %q", ve.Msg, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
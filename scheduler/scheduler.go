@@ -0,0 +1,165 @@
+// Package scheduler runs jobs stored in the database at their time: one-shot
+// reminders and recurring maintenance. Jobs live in the store, so a restart
+// neither loses them nor resets recurring intervals.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"tgbot/models"
+)
+
+// Store is the persistent job queue (database.JobRepository)
+type Store interface {
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Job, error)
+	Complete(ctx context.Context, id int64, next time.Time) error          // next нулевое — задача выполнена
+	Fail(ctx context.Context, id int64, msg string, retry time.Time) error // retry нулевое — больше не повторять
+	Every(ctx context.Context, key, kind string, interval time.Duration) error
+}
+
+// Handler runs a single job; an error makes the scheduler retry it later
+type Handler func(ctx context.Context, job models.Job) error
+
+// Options configures how often the store is polled and how failures are retried
+type Options struct {
+	Poll        time.Duration // как часто проверять наступившие задачи
+	Lease       time.Duration // через сколько задача запустится снова, если процесс упал во время её выполнения
+	Batch       int           // сколько задач забирать за один раз
+	MaxAttempts int           // после стольких неудач подряд разовая задача снимается
+	RetryDelay  time.Duration // пауза перед повтором, растёт с каждой неудачей
+}
+
+// DefaultOptions checks for due jobs twice a minute, which is precise enough
+// for reminders counted in minutes
+func DefaultOptions() Options {
+	return Options{
+		Poll:        30 * time.Second,
+		Lease:       10 * time.Minute,
+		Batch:       50,
+		MaxAttempts: 5,
+		RetryDelay:  time.Minute,
+	}
+}
+
+// Scheduler claims due jobs from the store and runs the handler of their kind
+type Scheduler struct {
+	store Store
+	opts  Options
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func New(store Store, opts Options) *Scheduler {
+	return &Scheduler{store: store, opts: opts, handlers: make(map[string]Handler)}
+}
+
+// Handle registers the handler of a job kind
+func (s *Scheduler) Handle(kind string, h Handler) {
+	s.mu.Lock()
+	s.handlers[kind] = h
+	s.mu.Unlock()
+}
+
+// Every registers the handler of a recurring job and makes sure the job runs
+// every interval. The job is keyed by its kind, so there is one per kind
+func (s *Scheduler) Every(ctx context.Context, kind string, interval time.Duration, h Handler) error {
+	s.Handle(kind, h)
+	return s.store.Every(ctx, kind, kind, interval)
+}
+
+// Run polls the store until stop is closed. Jobs run with ctx, so a job that
+// has started when stop closes is allowed to finish; Run returns after it
+func (s *Scheduler) Run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.opts.Poll)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx, stop)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue runs all jobs that are due, batch by batch
+func (s *Scheduler) runDue(ctx context.Context, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		jobs, err := s.store.Claim(ctx, time.Now(), s.opts.Lease, s.opts.Batch)
+		if err != nil {
+			log.Printf("scheduler: claim jobs: %v", err)
+			return
+		}
+		for _, job := range jobs {
+			s.run(ctx, job)
+		}
+		if len(jobs) < s.opts.Batch {
+			return
+		}
+	}
+}
+
+// run executes one job and records the outcome in the store
+func (s *Scheduler) run(ctx context.Context, job models.Job) {
+	s.mu.RLock()
+	h, ok := s.handlers[job.Kind]
+	s.mu.RUnlock()
+
+	var err error
+	if ok {
+		err = safeRun(ctx, h, job)
+	} else {
+		err = fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		var next time.Time
+		if job.Every > 0 {
+			next = now.Add(job.Every)
+		}
+		err = s.store.Complete(ctx, job.ID, next)
+	case !ok:
+		// Вид задачи убран из бота — снимаем её, в том числе периодическую
+		log.Printf("scheduler: job %d: %v", job.ID, err)
+		err = s.store.Fail(ctx, job.ID, err.Error(), time.Time{})
+	case job.Every > 0:
+		log.Printf("scheduler: job %d (%s) failed: %v", job.ID, job.Kind, err)
+		err = s.store.Fail(ctx, job.ID, err.Error(), now.Add(job.Every))
+	case job.Attempts >= s.opts.MaxAttempts:
+		log.Printf("scheduler: job %d (%s) failed %d times, giving up: %v", job.ID, job.Kind, job.Attempts, err)
+		err = s.store.Fail(ctx, job.ID, err.Error(), time.Time{})
+	default:
+		log.Printf("scheduler: job %d (%s) failed, will retry: %v", job.ID, job.Kind, err)
+		err = s.store.Fail(ctx, job.ID, err.Error(), now.Add(time.Duration(job.Attempts)*s.opts.RetryDelay))
+	}
+	if err != nil {
+		log.Printf("scheduler: save job %d: %v", job.ID, err)
+	}
+}
+
+// safeRun calls the handler and turns a panic into an error, so one bad job
+// does not stop the scheduler
+func safeRun(ctx context.Context, h Handler, job models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic in job %d (%s): %v\n%s", job.ID, job.Kind, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tgbot/models"
+)
+
+// outcome is what run reported to the store about a job
+type outcome struct {
+	failed bool
+	next   time.Time // Complete: следующий запуск; Fail: время повтора
+	msg    string
+}
+
+// fakeStore hands out prepared batches and records outcomes
+type fakeStore struct {
+	mu       sync.Mutex
+	batches  [][]models.Job
+	claims   int
+	outcomes map[int64]outcome
+}
+
+func newFakeStore(batches ...[]models.Job) *fakeStore {
+	return &fakeStore{batches: batches, outcomes: make(map[int64]outcome)}
+}
+
+func (f *fakeStore) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claims++
+	if len(f.batches) == 0 {
+		return nil, nil
+	}
+	jobs := f.batches[0]
+	f.batches = f.batches[1:]
+	return jobs, nil
+}
+
+func (f *fakeStore) Complete(ctx context.Context, id int64, next time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outcomes[id] = outcome{next: next}
+	return nil
+}
+
+func (f *fakeStore) Fail(ctx context.Context, id int64, msg string, retry time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outcomes[id] = outcome{failed: true, next: retry, msg: msg}
+	return nil
+}
+
+func (f *fakeStore) Every(ctx context.Context, key, kind string, interval time.Duration) error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	opts := Options{Poll: time.Minute, Lease: time.Minute, Batch: 10, MaxAttempts: 3, RetryDelay: time.Minute}
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name       string
+		job        models.Job
+		handlerErr error
+		panics     bool
+		wantFailed bool
+		wantAfter  time.Duration // через сколько следующий запуск или повтор; -1 — больше не запускать
+		wantMsg    string
+	}{
+		{name: "one-shot done", job: models.Job{Kind: "ok", Attempts: 1}, wantAfter: -1},
+		{name: "recurring moved forward", job: models.Job{Kind: "ok", Every: time.Hour, Attempts: 1}, wantAfter: time.Hour},
+		{name: "first failure retried", job: models.Job{Kind: "ok", Attempts: 1}, handlerErr: errBoom,
+			wantFailed: true, wantAfter: time.Minute, wantMsg: "boom"},
+		{name: "retry delay grows", job: models.Job{Kind: "ok", Attempts: 2}, handlerErr: errBoom,
+			wantFailed: true, wantAfter: 2 * time.Minute, wantMsg: "boom"},
+		{name: "gives up after max attempts", job: models.Job{Kind: "ok", Attempts: 3}, handlerErr: errBoom,
+			wantFailed: true, wantAfter: -1, wantMsg: "boom"},
+		{name: "recurring failure waits for the next interval", job: models.Job{Kind: "ok", Every: time.Hour, Attempts: 5}, handlerErr: errBoom,
+			wantFailed: true, wantAfter: time.Hour, wantMsg: "boom"},
+		{name: "panic is a failure", job: models.Job{Kind: "ok", Attempts: 1}, panics: true,
+			wantFailed: true, wantAfter: time.Minute, wantMsg: "panic: boom"},
+		{name: "unknown kind dropped", job: models.Job{Kind: "gone", Every: time.Hour, Attempts: 1},
+			wantFailed: true, wantAfter: -1, wantMsg: `no handler for job kind "gone"`},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			s := New(store, opts)
+			s.Handle("ok", func(ctx context.Context, job models.Job) error {
+				if tt.panics {
+					panic("boom")
+				}
+				return tt.handlerErr
+			})
+
+			job := tt.job
+			job.ID = int64(i + 1)
+			before := time.Now()
+			s.run(context.Background(), job)
+			after := time.Now()
+
+			got, ok := store.outcomes[job.ID]
+			if !ok {
+				t.Fatalf("job outcome was not saved")
+			}
+			if got.failed != tt.wantFailed || got.msg != tt.wantMsg {
+				t.Errorf("outcome = %+v, want failed %v with %q", got, tt.wantFailed, tt.wantMsg)
+			}
+			if tt.wantAfter < 0 {
+				if !got.next.IsZero() {
+					t.Errorf("next run at %v, want none", got.next)
+				}
+				return
+			}
+			if got.next.Before(before.Add(tt.wantAfter)) || got.next.After(after.Add(tt.wantAfter)) {
+				t.Errorf("next run in %v, want %v", got.next.Sub(before), tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestRunDueBatches(t *testing.T) {
+	batch := func(ids ...int64) []models.Job {
+		var jobs []models.Job
+		for _, id := range ids {
+			jobs = append(jobs, models.Job{ID: id, Kind: "ok", Attempts: 1})
+		}
+		return jobs
+	}
+
+	tests := []struct {
+		name       string
+		batches    [][]models.Job
+		wantClaims int
+		wantRuns   int
+	}{
+		{name: "nothing due", wantClaims: 1},
+		{name: "short batch stops", batches: [][]models.Job{batch(1)}, wantClaims: 1, wantRuns: 1},
+		{name: "full batches claim again", batches: [][]models.Job{batch(1, 2), batch(3, 4), batch(5)}, wantClaims: 3, wantRuns: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore(tt.batches...)
+			s := New(store, Options{Batch: 2, MaxAttempts: 3, RetryDelay: time.Minute})
+			runs := 0
+			s.Handle("ok", func(ctx context.Context, job models.Job) error {
+				runs++
+				return nil
+			})
+
+			s.runDue(context.Background(), make(chan struct{}))
+			if store.claims != tt.wantClaims || runs != tt.wantRuns {
+				t.Errorf("claims = %d, runs = %d, want %d and %d", store.claims, runs, tt.wantClaims, tt.wantRuns)
+			}
+		})
+	}
+
+	t.Run("stopped", func(t *testing.T) {
+		store := newFakeStore(batch(1, 2))
+		s := New(store, Options{Batch: 2})
+		stop := make(chan struct{})
+		close(stop)
+		s.runDue(context.Background(), stop)
+		if store.claims != 0 {
+			t.Errorf("claimed %d times after stop", store.claims)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package sender
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket: it holds up to burst tokens and refills at rate tokens per second
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	rate   float64
+	last   time.Time
+	until  time.Time // no sends before this moment (set from Telegram's retry_after)
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	return &bucket{tokens: float64(burst), burst: float64(burst), rate: rate, last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	if pause := b.until.Sub(now); pause > wait {
+		wait = pause
+	}
+	return wait
+}
+
+// pause blocks the bucket until now+d
+func (b *bucket) pause(now time.Time, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t := now.Add(d); t.After(b.until) {
+		b.until = t
+	}
+}
+
+// idle reports whether the bucket has been unused for at least d
+func (b *bucket) idle(now time.Time, d time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.last) >= d && now.After(b.until)
+}
@@ -0,0 +1,84 @@
+package sender
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketReserve(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		pause time.Duration // пауза от retry_after, выставленная в t0
+		at    []time.Duration
+		want  []time.Duration
+	}{
+		{name: "burst goes out at once", rate: 1, burst: 3,
+			at: []time.Duration{0, 0, 0}, want: []time.Duration{0, 0, 0}},
+		{name: "queue after the burst", rate: 2, burst: 1,
+			at: []time.Duration{0, 0, 0}, want: []time.Duration{0, 500 * time.Millisecond, time.Second}},
+		{name: "refills over time", rate: 1, burst: 1,
+			at: []time.Duration{0, time.Second, 1500 * time.Millisecond}, want: []time.Duration{0, 0, 500 * time.Millisecond}},
+		{name: "refill capped by burst", rate: 1, burst: 2,
+			at: []time.Duration{time.Hour, time.Hour, time.Hour}, want: []time.Duration{0, 0, time.Second}},
+		{name: "retry_after holds even with tokens", rate: 10, burst: 10, pause: 3 * time.Second,
+			at: []time.Duration{0, 2 * time.Second, 3 * time.Second}, want: []time.Duration{3 * time.Second, time.Second, 0}},
+		{name: "rate wait longer than the pause wins", rate: 0.1, burst: 1, pause: time.Second,
+			at: []time.Duration{0, 0}, want: []time.Duration{time.Second, 10 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(tt.rate, tt.burst)
+			b.last = t0
+			if tt.pause > 0 {
+				b.pause(t0, tt.pause)
+			}
+			for i, at := range tt.at {
+				if got := b.reserve(t0.Add(at)); got != tt.want[i] {
+					t.Errorf("reserve #%d at +%v = %v, want %v", i+1, at, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBucketPauseKeepsLatest(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := newBucket(1, 1)
+	b.pause(t0, 5*time.Second)
+	b.pause(t0, time.Second)
+	if want := t0.Add(5 * time.Second); !b.until.Equal(want) {
+		t.Errorf("until = %v, want the longer pause %v", b.until, want)
+	}
+}
+
+func TestBucketIdle(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		pause time.Duration
+		after time.Duration
+		want  bool
+	}{
+		{name: "recently used", after: time.Minute, want: false},
+		{name: "unused long enough", after: chatIdleTTL, want: true},
+		{name: "paused by retry_after", pause: 2 * chatIdleTTL, after: chatIdleTTL, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(1, 1)
+			b.reserve(t0)
+			if tt.pause > 0 {
+				b.pause(t0, tt.pause)
+			}
+			if got := b.idle(t0.Add(tt.after), chatIdleTTL); got != tt.want {
+				t.Errorf("idle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
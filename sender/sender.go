@@ -0,0 +1,193 @@
+// Package sender wraps the Telegram Bot API with rate limiting and retries so
+// that bursts of outgoing messages don't get dropped with 429 errors.
+package sender
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Options configures limits and retries of a Sender
+type Options struct {
+	GlobalRate  float64       // messages per second across all chats
+	GlobalBurst int           // messages that may go out at once
+	ChatRate    float64       // messages per second to a single chat
+	ChatBurst   int           // messages to a single chat that may go out at once
+	MaxRetries  int           // retries after a 429 or a transient error
+	BaseBackoff time.Duration // delay before the first retry, doubled each time
+}
+
+// DefaultOptions follows Telegram's documented limits: ~30 messages per
+// second overall and about one per second to the same chat
+func DefaultOptions() Options {
+	return Options{
+		GlobalRate:  25,
+		GlobalBurst: 25,
+		ChatRate:    1,
+		ChatBurst:   3,
+		MaxRetries:  3,
+		BaseBackoff: 500 * time.Millisecond,
+	}
+}
+
+// chatIdleTTL — how long an unused per-chat bucket is kept in memory
+const chatIdleTTL = 5 * time.Minute
+
+// Sender sends requests through tgbotapi.BotAPI respecting global and per-chat
+// limits. It is safe for concurrent use; Send blocks until the request is made.
+type Sender struct {
+	api  *tgbotapi.BotAPI
+	opts Options
+
+	global *bucket
+
+	mu        sync.Mutex
+	chats     map[int64]*bucket
+	lastSweep time.Time
+}
+
+// New wraps api with the given limits
+func New(api *tgbotapi.BotAPI, opts Options) *Sender {
+	return &Sender{
+		api:       api,
+		opts:      opts,
+		global:    newBucket(opts.GlobalRate, opts.GlobalBurst),
+		chats:     make(map[int64]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// API returns the wrapped client for calls that are not rate limited (updates, webhooks)
+func (s *Sender) API() *tgbotapi.BotAPI {
+	return s.api
+}
+
+// Send sends a message-like request and returns the sent message.
+// Errors are logged here, so callers may ignore them.
+func (s *Sender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := s.do(c, func() error {
+		var err error
+		msg, err = s.api.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+// Request makes a request that doesn't return a message, e.g. answering a callback query
+func (s *Sender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var resp *tgbotapi.APIResponse
+	err := s.do(c, func() error {
+		var err error
+		resp, err = s.api.Request(c)
+		return err
+	})
+	return resp, err
+}
+
+// do waits for the limiters and runs call, retrying on 429 and transient errors
+func (s *Sender) do(c tgbotapi.Chattable, call func() error) error {
+	chatID := chatOf(c)
+	chat := s.chatBucket(chatID)
+
+	backoff := s.opts.BaseBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		s.wait(chat)
+		err = call()
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.opts.MaxRetries {
+			break
+		}
+
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) {
+			if apiErr.Code == 429 && apiErr.RetryAfter > 0 {
+				// Telegram says exactly how long to wait; hold the chat until then
+				retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+				if chat != nil {
+					chat.pause(time.Now(), retryAfter)
+				} else {
+					s.global.pause(time.Now(), retryAfter)
+				}
+				continue
+			}
+			if apiErr.Code < 500 {
+				// 400/403 and similar won't succeed on retry (bad request, bot blocked)
+				break
+			}
+		}
+
+		// Network errors and 5xx: exponential backoff
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	log.Printf("send to chat %d failed: %v", chatID, err)
+	return err
+}
+
+// wait blocks until both the chat and the global limiter allow a request
+func (s *Sender) wait(chat *bucket) {
+	now := time.Now()
+	var d time.Duration
+	if chat != nil {
+		d = chat.reserve(now)
+	}
+	if g := s.global.reserve(now); g > d {
+		d = g
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// chatBucket returns the limiter for the chat; nil for requests without a chat
+func (s *Sender) chatBucket(chatID int64) *bucket {
+	if chatID == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > chatIdleTTL {
+		for id, b := range s.chats {
+			if b.idle(now, chatIdleTTL) {
+				delete(s.chats, id)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.chats[chatID]
+	if !ok {
+		b = newBucket(s.opts.ChatRate, s.opts.ChatBurst)
+		s.chats[chatID] = b
+	}
+	return b
+}
+
+// chatOf returns the target chat of a request; 0 for requests without a chat
+func chatOf(c tgbotapi.Chattable) int64 {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID
+	case tgbotapi.PhotoConfig:
+		return v.ChatID
+	case tgbotapi.DocumentConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package sender
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestDoRetries(t *testing.T) {
+	tooMany := &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+	badRequest := &tgbotapi.Error{Code: 400, Message: "Bad Request"}
+	serverError := &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}
+	network := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		chatID    int64
+		errs      []error // ответы на попытки по порядку, дальше — успех
+		wantCalls int
+		wantErr   error
+		minWait   time.Duration
+	}{
+		{name: "success", chatID: 1, wantCalls: 1},
+		{name: "bad request is not retried", chatID: 1, errs: []error{badRequest}, wantCalls: 1, wantErr: badRequest},
+		{name: "server error retried", chatID: 1, errs: []error{serverError, serverError}, wantCalls: 3},
+		{name: "network error gives up", chatID: 1, errs: []error{network, network, network, network, network}, wantCalls: 4, wantErr: network},
+		{name: "retry_after waits in the chat", chatID: 1, errs: []error{tooMany}, wantCalls: 2, minWait: time.Second},
+		{name: "retry_after without a chat waits globally", errs: []error{tooMany}, wantCalls: 2, minWait: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(nil, Options{GlobalRate: 1000, GlobalBurst: 100, ChatRate: 1000, ChatBurst: 100,
+				MaxRetries: 3, BaseBackoff: time.Millisecond})
+
+			calls := 0
+			start := time.Now()
+			err := s.do(tgbotapi.NewMessage(tt.chatID, "hi"), func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Errorf("retried after %v, want at least %v", elapsed, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestChatOf(t *testing.T) {
+	tests := []struct {
+		name string
+		c    tgbotapi.Chattable
+		want int64
+	}{
+		{name: "message", c: tgbotapi.NewMessage(42, "hi"), want: 42},
+		{name: "edit text", c: tgbotapi.NewEditMessageText(43, 1, "hi"), want: 43},
+		{name: "callback answer", c: tgbotapi.NewCallback("id", ""), want: 0},
+	}
+	for _, tt := range tests {
+		if got := chatOf(tt.c); got != tt.want {
+			t.Errorf("%s: chatOf = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
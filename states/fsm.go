@@ -1,7 +1,10 @@
 package states
 
 import (
+	"log"
 	"sync"
+	"time"
+
 	"tgbot/models"
 )
 
@@ -17,6 +20,16 @@ const (
 	EnteringNick       State = "entering_nick"
 	EnteringTag        State = "entering_tag"
 	TriathlonSelect    State = "triathlon_select"
+
+	// Состояния организатора при составлении рассылки
+	BroadcastClass   State = "broadcast_class"
+	BroadcastContent State = "broadcast_content"
+)
+
+// Режимы сессии: пустой — новая регистрация
+const (
+	ModeEdit      = "edit"      // редактирование уже сохранённой анкеты
+	ModeBroadcast = "broadcast" // организатор составляет рассылку
 )
 
 type Session struct {
@@ -24,40 +37,224 @@ type Session struct {
 	Temp        *models.User
 	CurrentGame string
 	TriGames    map[string]bool
+	Mode        string
+	LastActive  time.Time         // когда пользователь последний раз продвинулся по анкете
+	RemindedAt  time.Time         // когда ему напомнили о брошенной анкете; нулевое — не напоминали
+	Draft       *models.Broadcast // черновик рассылки организатора (ModeBroadcast)
+	Verifying   string            // ник, который проверяется на платформе дисциплины; пусто — проверки нет
+}
+
+func newSession(st State) *Session {
+	return &Session{
+		State:      st,
+		Temp:       &models.User{Disciplines: make(map[string]models.GameData)},
+		TriGames:   make(map[string]bool),
+		LastActive: time.Now(),
+	}
 }
 
+// touch records activity of the user: the session is fresh again and may be reminded about later
+func (s *Session) touch() {
+	s.LastActive = time.Now()
+	s.RemindedAt = time.Time{}
+}
+
+// clone returns a deep copy of the session that shares no maps with it
+func (s *Session) clone() *Session {
+	c := *s
+	if s.Temp != nil {
+		u := *s.Temp
+		u.Disciplines = make(map[string]models.GameData, len(s.Temp.Disciplines))
+		for game, gd := range s.Temp.Disciplines {
+			u.Disciplines[game] = gd
+		}
+		c.Temp = &u
+	}
+	if s.Draft != nil {
+		b := *s.Draft
+		c.Draft = &b
+	}
+	c.TriGames = make(map[string]bool, len(s.TriGames))
+	for game, ok := range s.TriGames {
+		c.TriGames[game] = ok
+	}
+	return &c
+}
+
+// Manager caches sessions in memory and writes every change through to a Store.
+// The map of sessions has one lock, held only for lookups; store I/O runs under
+// the lock of the user's own entry, so a slow query holds up only that user
 type Manager struct {
-	mu       sync.RWMutex
-	sessions map[int64]*Session
+	mu       sync.Mutex
+	sessions map[int64]*entry
+	store    Store
+}
+
+// entry is the cached session of one user; s is nil until it is loaded
+type entry struct {
+	mu sync.Mutex
+	s  *Session
 }
 
+// NewManager returns a manager backed by an in-memory store
 func NewManager() *Manager {
-	return &Manager{sessions: make(map[int64]*Session)}
+	return NewManagerWithStore(NewMemoryStore())
+}
+
+// NewManagerWithStore returns a manager that persists sessions in store
+func NewManagerWithStore(store Store) *Manager {
+	return &Manager{sessions: make(map[int64]*entry), store: store}
 }
 
 func (m *Manager) Get(userID int64) *Session {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	s, ok := m.sessions[userID]
-	if !ok {
-		s = &Session{State: StateIdle, Temp: &models.User{Disciplines: make(map[string]models.GameData)}, TriGames: make(map[string]bool)}
-		m.sessions[userID] = s
+	e := m.lock(userID)
+	defer e.mu.Unlock()
+	return e.s
+}
+
+// Snapshot returns the user's session as last saved to the store. Code outside
+// the user's own update handler (background jobs) must read sessions through
+// it: the handler changes the cached session, including its maps, without a
+// lock, so even copying it from another goroutine would race with the handler
+func (m *Manager) Snapshot(userID int64) *Session {
+	s, err := m.store.Load(userID)
+	if err != nil {
+		log.Printf("session load %d: %v", userID, err)
 	}
-	return s
+	return normalize(s)
 }
 
 func (m *Manager) SetState(userID int64, st State) {
+	e := m.lock(userID)
+	e.s.State = st
+	e.s.touch()
+	m.save(userID, e.s)
+	e.mu.Unlock()
+}
+
+// Save persists the current session of the user without changing its state
+func (m *Manager) Save(userID int64) {
+	e := m.lock(userID)
+	e.s.touch()
+	m.save(userID, e.s)
+	e.mu.Unlock()
+}
+
+// Touch records activity of a user who is in the middle of a form, e.g. a
+// rejected answer that leaves the state unchanged
+func (m *Manager) Touch(userID int64) {
+	e := m.lock(userID)
+	if e.s.State != StateIdle {
+		e.s.touch()
+		m.save(userID, e.s)
+	}
+	e.mu.Unlock()
+}
+
+// MarkReminded records that the user was reminded about the unfinished form.
+// Only the stored session changes: the next answer of the user saves the
+// cached one with the mark cleared, as it should be
+func (m *Manager) MarkReminded(userID int64, at time.Time) {
+	if err := m.store.MarkReminded(userID, at); err != nil {
+		log.Printf("session mark reminded %d: %v", userID, err)
+	}
+}
+
+// Unfinished returns the users in the middle of a form
+func (m *Manager) Unfinished() ([]int64, error) {
+	return m.store.Unfinished()
+}
+
+// Stale returns the users with an unfinished session inactive since before
+// who have not been reminded yet
+func (m *Manager) Stale(before time.Time) ([]int64, error) {
+	return m.store.Stale(before)
+}
+
+// Expire drops unfinished sessions inactive since before and evicts every
+// cached session not used since then. Returns the users whose forms were dropped.
+// An entry that is busy right now is in use, so it stays
+func (m *Manager) Expire(before time.Time) ([]int64, error) {
+	ids, err := m.store.Expire(before)
 	m.mu.Lock()
-	if s, ok := m.sessions[userID]; ok {
-		s.State = st
-	} else {
-		m.sessions[userID] = &Session{State: st, Temp: &models.User{Disciplines: make(map[string]models.GameData)}, TriGames: make(map[string]bool)}
+	for _, id := range ids {
+		delete(m.sessions, id)
+	}
+	for id, e := range m.sessions {
+		if !e.mu.TryLock() {
+			continue
+		}
+		if e.s == nil || e.s.LastActive.Before(before) {
+			delete(m.sessions, id)
+		}
+		e.mu.Unlock()
 	}
 	m.mu.Unlock()
+	return ids, err
 }
 
+// Reset drops the session of the user: the cached one starts over idle
 func (m *Manager) Reset(userID int64) {
+	e := m.entry(userID)
+	e.mu.Lock()
+	e.s = newSession(StateIdle)
+	if err := m.store.Delete(userID); err != nil {
+		log.Printf("session delete %d: %v", userID, err)
+	}
+	e.mu.Unlock()
+}
+
+// entry returns the cache entry of the user, adding an empty one if needed
+func (m *Manager) entry(userID int64) *entry {
 	m.mu.Lock()
-	delete(m.sessions, userID)
-	m.mu.Unlock()
+	defer m.mu.Unlock()
+	e, ok := m.sessions[userID]
+	if !ok {
+		e = &entry{}
+		m.sessions[userID] = e
+	}
+	return e
+}
+
+// lock returns the locked entry of the user with the session loaded
+func (m *Manager) lock(userID int64) *entry {
+	e := m.entry(userID)
+	e.mu.Lock()
+	if e.s == nil {
+		e.s = m.load(userID)
+	}
+	return e
+}
+
+// load reads a session from the store; must be called with the entry locked
+func (m *Manager) load(userID int64) *Session {
+	s, err := m.store.Load(userID)
+	if err != nil {
+		log.Printf("session load %d: %v", userID, err)
+	}
+	return normalize(s)
+}
+
+// normalize fills in what an old or missing stored session lacks
+func normalize(s *Session) *Session {
+	if s == nil {
+		return newSession(StateIdle)
+	}
+	if s.LastActive.IsZero() {
+		s.LastActive = time.Now()
+	}
+	if s.Temp == nil {
+		s.Temp = &models.User{}
+	}
+	if s.Temp.Disciplines == nil {
+		s.Temp.Disciplines = make(map[string]models.GameData)
+	}
+	return s
+}
+
+// save writes a session to the store; must be called with the entry locked
+func (m *Manager) save(userID int64, s *Session) {
+	if err := m.store.Save(userID, s); err != nil {
+		log.Printf("session save %d: %v", userID, err)
+	}
 }
@@ -0,0 +1,191 @@
+package states
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"tgbot/models"
+)
+
+func TestManagerRestoresSessions(t *testing.T) {
+	tests := []struct {
+		name  string
+		run   func(m *Manager)
+		want  State
+		check func(t *testing.T, s *Session)
+	}{
+		{name: "new user starts idle", run: func(m *Manager) { m.Get(1) }, want: StateIdle},
+		{name: "state survives restart", run: func(m *Manager) { m.SetState(1, WaitingClass) }, want: WaitingClass},
+		{name: "form data survives restart", want: EnteringTag,
+			run: func(m *Manager) {
+				s := m.Get(1)
+				s.Temp.FirstName = "Иван"
+				s.Temp.Disciplines["Brawl Stars"] = models.GameData{Nick: "Ivan"}
+				s.CurrentGame = "Brawl Stars"
+				s.TriGames["Brawl Stars"] = true
+				m.SetState(1, EnteringTag)
+			},
+			check: func(t *testing.T, s *Session) {
+				if s.Temp.FirstName != "Иван" || s.Temp.Disciplines["Brawl Stars"].Nick != "Ivan" ||
+					s.CurrentGame != "Brawl Stars" || !s.TriGames["Brawl Stars"] {
+					t.Errorf("restored session = %+v, temp %+v", s, s.Temp)
+				}
+			}},
+		{name: "unsaved changes are lost", want: WaitingName,
+			run: func(m *Manager) {
+				m.SetState(1, WaitingName)
+				m.Get(1).Temp.FirstName = "Иван"
+			},
+			check: func(t *testing.T, s *Session) {
+				if s.Temp.FirstName != "" {
+					t.Errorf("first name %q was never saved", s.Temp.FirstName)
+				}
+			}},
+		{name: "reset forgets the session", want: StateIdle,
+			run: func(m *Manager) {
+				m.SetState(1, WaitingName)
+				m.Reset(1)
+			}},
+		{name: "broadcast draft survives restart", want: BroadcastContent,
+			run: func(m *Manager) {
+				s := m.Get(1)
+				s.Mode = ModeBroadcast
+				s.Draft = &models.Broadcast{Text: "Турнир в пятницу"}
+				m.SetState(1, BroadcastContent)
+			},
+			check: func(t *testing.T, s *Session) {
+				if s.Mode != ModeBroadcast || s.Draft == nil || s.Draft.Text != "Турнир в пятницу" {
+					t.Errorf("restored mode %q, draft %+v", s.Mode, s.Draft)
+				}
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			tt.run(NewManagerWithStore(store))
+
+			s := NewManagerWithStore(store).Get(1)
+			if s.State != tt.want {
+				t.Errorf("state after restart = %q, want %q", s.State, tt.want)
+			}
+			if s.Temp == nil || s.Temp.Disciplines == nil || s.TriGames == nil {
+				t.Fatalf("restored session has nil fields: %+v", s)
+			}
+			if tt.check != nil {
+				tt.check(t, s)
+			}
+		})
+	}
+}
+
+func TestManagerSnapshotIsACopy(t *testing.T) {
+	m := NewManager()
+	s := m.Get(1)
+	s.Temp.Disciplines["Chess"] = models.GameData{Nick: "magnus"}
+	m.SetState(1, ChoosingDiscipline)
+
+	snap := m.Snapshot(1)
+	s.Temp.Disciplines["Chess"] = models.GameData{Nick: "changed"}
+	delete(s.Temp.Disciplines, "Chess")
+	if snap.Temp.Disciplines["Chess"].Nick != "magnus" {
+		t.Errorf("snapshot changed with the cached session: %+v", snap.Temp.Disciplines)
+	}
+	if m.Snapshot(2).State != StateIdle {
+		t.Errorf("snapshot of an unknown user is not idle")
+	}
+}
+
+func TestManagerNormalizesStoredSessions(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(1, &Session{State: WaitingClass})
+
+	s := NewManagerWithStore(store).Get(1)
+	if s.State != WaitingClass || s.Temp == nil || s.Temp.Disciplines == nil || s.LastActive.IsZero() {
+		t.Errorf("session was not normalized: %+v", s)
+	}
+}
+
+func TestManagerReminders(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		run       func(m *Manager)
+		wantStale []int64
+	}{
+		{name: "inactive form is stale", wantStale: []int64{1},
+			run: func(m *Manager) {}},
+		{name: "reminded form is not stale", wantStale: nil,
+			run: func(m *Manager) { m.MarkReminded(1, now) }},
+		{name: "answer after a reminder clears the mark", wantStale: nil,
+			run: func(m *Manager) {
+				m.MarkReminded(1, now)
+				m.Touch(1)
+			}},
+		{name: "idle user is never stale", wantStale: nil,
+			run: func(m *Manager) { m.SetState(1, StateIdle) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			store.Save(1, &Session{State: WaitingName, LastActive: hourAgo})
+			m := NewManagerWithStore(store)
+			tt.run(m)
+
+			stale, err := m.Stale(now.Add(-time.Minute))
+			if err != nil {
+				t.Fatalf("Stale: %v", err)
+			}
+			if !reflect.DeepEqual(stale, tt.wantStale) {
+				t.Errorf("Stale = %v, want %v", stale, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestManagerTouch(t *testing.T) {
+	m := NewManager()
+	m.Touch(1)
+	if ids, _ := m.Unfinished(); len(ids) != 0 {
+		t.Errorf("touching an idle user saved a session: %v", ids)
+	}
+
+	m.SetState(1, WaitingName)
+	m.MarkReminded(1, time.Now())
+	m.Touch(1)
+	if s := m.Snapshot(1); !s.RemindedAt.IsZero() {
+		t.Errorf("RemindedAt = %v after an answer, want zero", s.RemindedAt)
+	}
+}
+
+func TestManagerExpire(t *testing.T) {
+	store := NewMemoryStore()
+	old := time.Now().Add(-2 * time.Hour)
+	store.Save(1, &Session{State: WaitingName, LastActive: old})
+	store.Save(2, &Session{State: StateIdle, LastActive: old})
+	m := NewManagerWithStore(store)
+	m.Get(1)
+	m.Get(2)
+	m.SetState(3, WaitingClass)
+
+	ids, err := m.Expire(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1}) {
+		t.Errorf("expired %v, want [1]", ids)
+	}
+	if s := m.Get(1); s.State != StateIdle {
+		t.Errorf("expired user state = %q, want idle", s.State)
+	}
+	if s := m.Get(3); s.State != WaitingClass {
+		t.Errorf("active user state = %q, want %q", s.State, WaitingClass)
+	}
+	if got, _ := m.Unfinished(); !reflect.DeepEqual(got, []int64{3}) {
+		t.Errorf("Unfinished = %v, want [3]", got)
+	}
+}
@@ -0,0 +1,107 @@
+package states
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists sessions so that a restart resumes every user where they left off.
+// Load returns nil and no error when the user has no saved session.
+type Store interface {
+	Load(userID int64) (*Session, error)
+	Save(userID int64, s *Session) error
+	Delete(userID int64) error
+	// MarkReminded sets RemindedAt of the saved session, if there is one
+	MarkReminded(userID int64, at time.Time) error
+	// Unfinished lists users in the middle of a form
+	Unfinished() ([]int64, error)
+	// Stale lists users with an unfinished session, inactive since before and not reminded yet
+	Stale(before time.Time) ([]int64, error)
+	// Expire deletes unfinished sessions inactive since before and returns their users
+	Expire(before time.Time) ([]int64, error)
+}
+
+// MemoryStore keeps copies of sessions in a map, so the manager's sessions can
+// change while the store is read; it is the default store and is lost on restart
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[int64]*Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[int64]*Session)}
+}
+
+func (s *MemoryStore) Load(userID int64) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[userID]
+	if !ok {
+		return nil, nil
+	}
+	return sess.clone(), nil
+}
+
+func (s *MemoryStore) Save(userID int64, sess *Session) error {
+	s.mu.Lock()
+	s.sessions[userID] = sess.clone()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Delete(userID int64) error {
+	s.mu.Lock()
+	delete(s.sessions, userID)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) MarkReminded(userID int64, at time.Time) error {
+	s.mu.Lock()
+	if sess, ok := s.sessions[userID]; ok {
+		sess.RemindedAt = at
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Unfinished() ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []int64
+	for id, sess := range s.sessions {
+		if sess.State != StateIdle {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *MemoryStore) Stale(before time.Time) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []int64
+	for id, sess := range s.sessions {
+		if sess.State != StateIdle && !sess.LastActive.After(before) && sess.RemindedAt.IsZero() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *MemoryStore) Expire(before time.Time) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int64
+	for id, sess := range s.sessions {
+		if sess.State != StateIdle && !sess.LastActive.After(before) {
+			ids = append(ids, id)
+			delete(s.sessions, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
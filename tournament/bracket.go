@@ -0,0 +1,186 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"tgbot/models"
+)
+
+// Options controls the shape of the generated bracket
+type Options struct {
+	GroupSize int // максимум игроков в группе
+	Advance   int // сколько лучших игроков группы выходит в плей-офф
+}
+
+var DefaultOptions = Options{GroupSize: 4, Advance: 2}
+
+var ErrNotEnoughPlayers = errors.New("tournament: need at least 2 players")
+
+// Generate splits players into groups, creates round-robin group matches and
+// an empty single-elimination playoff whose first round references group places
+// ("A1", "B2"). Players are seeded in the given order, shuffle them beforehand.
+func Generate(discipline string, players []int64, opts Options) ([]models.Match, error) {
+	if len(players) < 2 {
+		return nil, ErrNotEnoughPlayers
+	}
+	if opts.GroupSize < 2 {
+		opts.GroupSize = DefaultOptions.GroupSize
+	}
+	if opts.Advance < 1 {
+		opts.Advance = DefaultOptions.Advance
+	}
+
+	groupCount := (len(players) + opts.GroupSize - 1) / opts.GroupSize
+	groups := make([][]int64, groupCount)
+	for i, p := range players {
+		groups[i%groupCount] = append(groups[i%groupCount], p)
+	}
+
+	var matches []models.Match
+	minSize := len(players)
+	for i, g := range groups {
+		if len(g) < minSize {
+			minSize = len(g)
+		}
+		matches = append(matches, roundRobin(discipline, groupName(i), g)...)
+	}
+
+	advance := opts.Advance
+	if advance > minSize {
+		advance = minSize
+	}
+	matches = append(matches, playoff(discipline, groupCount, advance)...)
+	return matches, nil
+}
+
+// groupName returns the letter of the group by its index: A, B, C...
+func groupName(i int) string {
+	return string(rune('A' + i))
+}
+
+// roundRobin pairs every player of the group with every other (circle method)
+func roundRobin(discipline, group string, players []int64) []models.Match {
+	ps := append([]int64(nil), players...)
+	if len(ps)%2 == 1 {
+		ps = append(ps, 0) // 0 — свободный от игры в этом туре
+	}
+	n := len(ps)
+
+	var matches []models.Match
+	for round := 1; round < n; round++ {
+		slot := 0
+		for i := 0; i < n/2; i++ {
+			p1, p2 := ps[i], ps[n-1-i]
+			if p1 == 0 || p2 == 0 {
+				continue
+			}
+			matches = append(matches, models.Match{
+				Discipline: discipline,
+				Stage:      models.StageGroup,
+				GroupName:  group,
+				Round:      round,
+				Slot:       slot,
+				Player1:    p1,
+				Player2:    p2,
+				Status:     models.MatchPending,
+			})
+			slot++
+		}
+		// вращаем всех, кроме первого
+		last := ps[n-1]
+		copy(ps[2:], ps[1:n-1])
+		ps[1] = last
+	}
+	return matches
+}
+
+// playoff builds an empty bracket for groupCount*advance qualifiers, padded with
+// byes to the next power of two. Group winners are seeded first and lower places
+// are rotated so that players from one group do not meet in the first round.
+func playoff(discipline string, groupCount, advance int) []models.Match {
+	if groupCount*advance < 2 {
+		return nil
+	}
+	size := 2
+	for size < groupCount*advance {
+		size *= 2
+	}
+	order := seedOrder(size)
+
+	var labels []string
+	for shift := 0; shift < groupCount; shift++ {
+		labels = qualifierLabels(groupCount, advance, shift)
+		if !firstRoundClash(labels, order) {
+			break
+		}
+	}
+
+	var matches []models.Match
+	round := 1
+	for n := size / 2; n >= 1; n /= 2 {
+		for slot := 0; slot < n; slot++ {
+			m := models.Match{
+				Discipline: discipline,
+				Stage:      models.StagePlayoff,
+				Round:      round,
+				Slot:       slot,
+				Status:     models.MatchPending,
+			}
+			if round == 1 {
+				m.Source1 = seedLabel(labels, order[2*slot])
+				m.Source2 = seedLabel(labels, order[2*slot+1])
+			}
+			matches = append(matches, m)
+		}
+		round++
+	}
+	return matches
+}
+
+// qualifierLabels lists group places in seed order: all winners, then all
+// runners-up and so on; places below the first are rotated by shift groups
+func qualifierLabels(groupCount, advance, shift int) []string {
+	var labels []string
+	for place := 1; place <= advance; place++ {
+		for i := 0; i < groupCount; i++ {
+			g := i
+			if place > 1 {
+				g = (i + shift) % groupCount
+			}
+			labels = append(labels, fmt.Sprintf("%s%d", groupName(g), place))
+		}
+	}
+	return labels
+}
+
+// firstRoundClash reports whether two players of the same group meet in the first round
+func firstRoundClash(labels []string, order []int) bool {
+	for i := 0; i+1 < len(order); i += 2 {
+		a, b := seedLabel(labels, order[i]), seedLabel(labels, order[i+1])
+		if a != "" && b != "" && a[0] == b[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func seedLabel(labels []string, seed int) string {
+	if seed > len(labels) {
+		return "" // bye
+	}
+	return labels[seed-1]
+}
+
+// seedOrder returns the standard bracket order so that top seeds meet last:
+// for 4 it is [1 4 2 3], for 8 — [1 8 4 5 2 7 3 6]
+func seedOrder(size int) []int {
+	order := []int{1}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, s := range order {
+			next = append(next, s, n+1-s)
+		}
+		order = next
+	}
+	return order
+}
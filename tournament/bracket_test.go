@@ -0,0 +1,149 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tgbot/models"
+)
+
+func players(n int) []int64 {
+	ps := make([]int64, n)
+	for i := range ps {
+		ps[i] = int64(i + 1)
+	}
+	return ps
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name       string
+		players    int
+		opts       Options
+		groupGames int      // матчей на групповом этапе
+		playoff    int      // матчей в плей-офф
+		firstRound []string // пары первого круга плей-офф, "" — свободный слот
+		wantErr    error
+	}{
+		{name: "no players", players: 0, wantErr: ErrNotEnoughPlayers},
+		{name: "one player", players: 1, wantErr: ErrNotEnoughPlayers},
+		{name: "two players", players: 2, opts: DefaultOptions,
+			groupGames: 1, playoff: 1, firstRound: []string{"A1-A2"}},
+		{name: "odd group", players: 3, opts: DefaultOptions,
+			groupGames: 3, playoff: 1, firstRound: []string{"A1-A2"}},
+		{name: "zero options fall back to defaults", players: 4,
+			groupGames: 6, playoff: 1, firstRound: []string{"A1-A2"}},
+		{name: "uneven groups", players: 5, opts: DefaultOptions,
+			groupGames: 4, playoff: 3, firstRound: []string{"A1-B2", "B1-A2"}},
+		{name: "advance capped by the smallest group", players: 5, opts: Options{GroupSize: 4, Advance: 3},
+			groupGames: 4, playoff: 3, firstRound: []string{"A1-B2", "B1-A2"}},
+		{name: "byes for top seeds", players: 6, opts: Options{GroupSize: 2, Advance: 1},
+			groupGames: 3, playoff: 3, firstRound: []string{"A1-", "B1-C1"}},
+		{name: "same group rotated apart", players: 9, opts: DefaultOptions,
+			groupGames: 9, playoff: 7, firstRound: []string{"A1-", "B2-C2", "B1-", "C1-A2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := Generate("Test", players(tt.players), tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			var groupGames, playoff int
+			var firstRound []string
+			for _, m := range matches {
+				if m.Status != models.MatchPending || m.Discipline != "Test" {
+					t.Errorf("match %+v: want a pending Test match", m)
+				}
+				switch m.Stage {
+				case models.StageGroup:
+					groupGames++
+				case models.StagePlayoff:
+					playoff++
+					if m.Round == 1 {
+						firstRound = append(firstRound, m.Source1+"-"+m.Source2)
+					}
+				}
+			}
+			if groupGames != tt.groupGames || playoff != tt.playoff {
+				t.Errorf("got %d group and %d playoff matches, want %d and %d", groupGames, playoff, tt.groupGames, tt.playoff)
+			}
+			if !reflect.DeepEqual(firstRound, tt.firstRound) {
+				t.Errorf("first playoff round = %q, want %q", firstRound, tt.firstRound)
+			}
+			checkRoundRobin(t, matches)
+		})
+	}
+}
+
+// checkRoundRobin checks that in every group each pair of players meets exactly
+// once and nobody plays twice in one round
+func checkRoundRobin(t *testing.T, matches []models.Match) {
+	t.Helper()
+	members := make(map[string]map[int64]bool)
+	met := make(map[string]int)
+	busy := make(map[string]bool)
+	for _, m := range matches {
+		if m.Stage != models.StageGroup {
+			continue
+		}
+		if m.Player1 == 0 || m.Player2 == 0 || m.Player1 == m.Player2 {
+			t.Errorf("group match %+v has bad players", m)
+			continue
+		}
+		if members[m.GroupName] == nil {
+			members[m.GroupName] = make(map[int64]bool)
+		}
+		for _, p := range []int64{m.Player1, m.Player2} {
+			members[m.GroupName][p] = true
+			key := fmt.Sprintf("%s/%d/%d", m.GroupName, m.Round, p)
+			if busy[key] {
+				t.Errorf("player %d plays twice in group %s round %d", p, m.GroupName, m.Round)
+			}
+			busy[key] = true
+		}
+		a, b := min(m.Player1, m.Player2), max(m.Player1, m.Player2)
+		met[fmt.Sprintf("%s/%d-%d", m.GroupName, a, b)]++
+	}
+	for g, ps := range members {
+		n := len(ps)
+		pairs := 0
+		for k, times := range met {
+			if strings.HasPrefix(k, g+"/") {
+				pairs++
+				if times != 1 {
+					t.Errorf("pair %s met %d times", k, times)
+				}
+			}
+		}
+		if pairs != n*(n-1)/2 {
+			t.Errorf("group %s: %d pairs for %d players, want %d", g, pairs, n, n*(n-1)/2)
+		}
+	}
+}
+
+func TestSeedOrder(t *testing.T) {
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	}
+	for _, tt := range tests {
+		if got := seedOrder(tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seedOrder(%d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
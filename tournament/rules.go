@@ -0,0 +1,109 @@
+package tournament
+
+import (
+	"errors"
+	"tgbot/models"
+	"tgbot/registry"
+)
+
+// Format describes how a match of the discipline is scored
+type Format struct {
+	WinsNeeded int  // побед для выигрыша матча: 2 — best-of-3, 1 — одна игра
+	GroupDraws bool // разрешена ли ничья на групповом этапе
+}
+
+var ErrInvalidScore = errors.New("tournament: score is not allowed by the rules")
+
+// FormatOf returns the scoring format of the discipline from the registry
+// (single game without draws for unknown disciplines)
+func FormatOf(discipline string) Format {
+	if d, ok := registry.ByName(discipline); ok {
+		return Format{WinsNeeded: d.WinsNeeded, GroupDraws: d.GroupDraws}
+	}
+	return Format{WinsNeeded: 1}
+}
+
+// Score is a match result from the point of view of one player
+type Score struct {
+	Mine   int
+	Theirs int
+}
+
+// Draw reports whether the score is a draw
+func (s Score) Draw() bool {
+	return s.Mine == s.Theirs
+}
+
+// ReportOptions lists the results a player may report on a stage: own wins and a draw if allowed
+func (f Format) ReportOptions(stage string) []Score {
+	var opts []Score
+	for lost := 0; lost < f.WinsNeeded; lost++ {
+		opts = append(opts, Score{Mine: f.WinsNeeded, Theirs: lost})
+	}
+	if stage == models.StageGroup && f.GroupDraws {
+		opts = append(opts, Score{})
+	}
+	return opts
+}
+
+// Validate checks that the score is a finished match under the format
+func (f Format) Validate(stage string, score1, score2 int) error {
+	if score1 < 0 || score2 < 0 {
+		return ErrInvalidScore
+	}
+	if score1 == score2 {
+		if stage == models.StageGroup && f.GroupDraws && score1 < f.WinsNeeded {
+			return nil
+		}
+		return ErrInvalidScore
+	}
+	hi, lo := score1, score2
+	if lo > hi {
+		hi, lo = lo, hi
+	}
+	if hi != f.WinsNeeded || lo >= f.WinsNeeded {
+		return ErrInvalidScore
+	}
+	return nil
+}
+
+// ApplyResult records the confirmed score of matches[i], moves the winner to the
+// next playoff round and seeds the playoff once the last group match is finished.
+// It returns indexes of all changed matches.
+func ApplyResult(matches []models.Match, i int, score1, score2 int) ([]int, error) {
+	m := &matches[i]
+	if m.Player1 == 0 || m.Player2 == 0 {
+		return nil, errors.New("tournament: match has no opponents yet")
+	}
+	if err := FormatOf(m.Discipline).Validate(m.Stage, score1, score2); err != nil {
+		return nil, err
+	}
+
+	m.Score1, m.Score2 = score1, score2
+	switch {
+	case score1 > score2:
+		m.Winner = m.Player1
+	case score2 > score1:
+		m.Winner = m.Player2
+	default:
+		m.Winner = 0
+	}
+	m.Status = models.MatchDone
+
+	changed := []int{i}
+	if m.Stage == models.StagePlayoff {
+		if next := advanceWinner(matches, i); next >= 0 {
+			changed = append(changed, next)
+		}
+		return changed, nil
+	}
+
+	if GroupStageFinished(matches) {
+		seeded, err := SeedPlayoff(matches)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, seeded...)
+	}
+	return changed, nil
+}
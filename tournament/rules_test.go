@@ -0,0 +1,182 @@
+package tournament
+
+import (
+	"errors"
+	"testing"
+
+	"tgbot/models"
+)
+
+// index finds the match by its place in the bracket
+func index(t *testing.T, matches []models.Match, stage, group string, round, slot int) int {
+	t.Helper()
+	for i, m := range matches {
+		if m.Stage == stage && m.GroupName == group && m.Round == round && m.Slot == slot {
+			return i
+		}
+	}
+	t.Fatalf("no %s match %s/%d/%d", stage, group, round, slot)
+	return -1
+}
+
+// finishGroups lets the lower Telegram ID win every group match
+func finishGroups(t *testing.T, matches []models.Match) {
+	t.Helper()
+	for i, m := range matches {
+		if m.Stage != models.StageGroup {
+			continue
+		}
+		s1, s2 := 1, 0
+		if m.Player2 < m.Player1 {
+			s1, s2 = 0, 1
+		}
+		if _, err := ApplyResult(matches, i, s1, s2); err != nil {
+			t.Fatalf("group match %d: %v", i, err)
+		}
+	}
+}
+
+func TestApplyResult(t *testing.T) {
+	tests := []struct {
+		name           string
+		discipline     string
+		stage          string
+		player1        int64
+		player2        int64
+		score1, score2 int
+		wantWinner     int64
+		wantErr        bool
+	}{
+		{name: "first player wins", discipline: "Test", stage: models.StageGroup, player1: 1, player2: 2, score1: 1, wantWinner: 1},
+		{name: "second player wins", discipline: "Test", stage: models.StagePlayoff, player1: 1, player2: 2, score2: 1, wantWinner: 2},
+		{name: "unknown discipline plays one game", discipline: "Test", stage: models.StageGroup, player1: 1, player2: 2, score1: 2, wantErr: true},
+		{name: "no draws without group draws", discipline: "Test", stage: models.StageGroup, player1: 1, player2: 2, wantErr: true},
+		{name: "group draw allowed", discipline: "Chess", stage: models.StageGroup, player1: 1, player2: 2},
+		{name: "no draws in playoff", discipline: "Chess", stage: models.StagePlayoff, player1: 1, player2: 2, wantErr: true},
+		{name: "best of three", discipline: "Brawl Stars", stage: models.StagePlayoff, player1: 1, player2: 2, score1: 1, score2: 2, wantWinner: 2},
+		{name: "best of three unfinished", discipline: "Brawl Stars", stage: models.StagePlayoff, player1: 1, player2: 2, score1: 1, wantErr: true},
+		{name: "negative score", discipline: "Test", stage: models.StageGroup, player1: 1, player2: 2, score1: 1, score2: -1, wantErr: true},
+		{name: "opponent not decided", discipline: "Test", stage: models.StagePlayoff, player1: 1, score1: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := []models.Match{{Discipline: tt.discipline, Stage: tt.stage, GroupName: "A", Round: 1,
+				Player1: tt.player1, Player2: tt.player2, Status: models.MatchPending}}
+			changed, err := ApplyResult(matches, 0, tt.score1, tt.score2)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ApplyResult(%d, %d) succeeded, want an error", tt.score1, tt.score2)
+				}
+				if matches[0].Status != models.MatchPending {
+					t.Errorf("rejected result changed the match: %+v", matches[0])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyResult: %v", err)
+			}
+			m := matches[0]
+			if m.Status != models.MatchDone || m.Winner != tt.wantWinner || m.Score1 != tt.score1 || m.Score2 != tt.score2 {
+				t.Errorf("match = %+v, want done %d:%d won by %d", m, tt.score1, tt.score2, tt.wantWinner)
+			}
+			if len(changed) == 0 || changed[0] != 0 {
+				t.Errorf("changed = %v, want the match first", changed)
+			}
+		})
+	}
+}
+
+func TestSeedPlayoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		opts    Options
+		// игроки первого круга плей-офф после посева, по слотам
+		want [][2]int64
+		// сразу прошедшие дальше благодаря свободному слоту
+		byes []int64
+	}{
+		{name: "single group", players: 3, opts: DefaultOptions,
+			want: [][2]int64{{1, 2}}},
+		{name: "two groups", players: 5, opts: DefaultOptions,
+			want: [][2]int64{{1, 4}, {2, 3}}},
+		{name: "bye for the top seed", players: 6, opts: Options{GroupSize: 2, Advance: 1},
+			want: [][2]int64{{1, 0}, {2, 3}}, byes: []int64{1}},
+		{name: "two byes", players: 9, opts: DefaultOptions,
+			want: [][2]int64{{1, 0}, {5, 6}, {2, 0}, {3, 4}}, byes: []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := Generate("Test", players(tt.players), tt.opts)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if _, err := SeedPlayoff(matches); !errors.Is(err, ErrGroupStageNotFinished) {
+				t.Fatalf("SeedPlayoff before the groups: err = %v, want %v", err, ErrGroupStageNotFinished)
+			}
+
+			// Последний групповой результат сам запускает посев
+			finishGroups(t, matches)
+
+			var got [][2]int64
+			for _, m := range matches {
+				if m.Stage == models.StagePlayoff && m.Round == 1 {
+					got = append(got, [2]int64{m.Player1, m.Player2})
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("first round = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("first round = %v, want %v", got, tt.want)
+					break
+				}
+			}
+
+			next := make(map[int64]bool)
+			for _, m := range matches {
+				if m.Stage == models.StagePlayoff && m.Round == 2 {
+					next[m.Player1], next[m.Player2] = true, true
+				}
+			}
+			for _, p := range tt.byes {
+				if !next[p] {
+					t.Errorf("player %d with a bye is not in round 2", p)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyResultAdvancesWinner(t *testing.T) {
+	matches, err := Generate("Test", players(6), Options{GroupSize: 2, Advance: 1})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	finishGroups(t, matches)
+
+	// Полуфинал B1-C1: побеждает 3, он встречается в финале с 1, прошедшим без игры
+	semi := index(t, matches, models.StagePlayoff, "", 1, 1)
+	final := index(t, matches, models.StagePlayoff, "", 2, 0)
+	changed, err := ApplyResult(matches, semi, 0, 1)
+	if err != nil {
+		t.Fatalf("semi-final: %v", err)
+	}
+	if len(changed) != 2 || changed[0] != semi || changed[1] != final {
+		t.Errorf("changed = %v, want [%d %d]", changed, semi, final)
+	}
+	if f := matches[final]; f.Player1 != 1 || f.Player2 != 3 {
+		t.Fatalf("final = %d vs %d, want 1 vs 3", f.Player1, f.Player2)
+	}
+
+	changed, err = ApplyResult(matches, final, 1, 0)
+	if err != nil {
+		t.Fatalf("final: %v", err)
+	}
+	if len(changed) != 1 || matches[final].Winner != 1 {
+		t.Errorf("final: changed = %v, winner = %d, want only the final won by 1", changed, matches[final].Winner)
+	}
+}
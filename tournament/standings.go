@@ -0,0 +1,174 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"tgbot/models"
+)
+
+// Standing is a row of the group table
+type Standing struct {
+	Player       int64
+	Played       int
+	Wins         int
+	Draws        int
+	Losses       int
+	Points       int
+	ScoreFor     int
+	ScoreAgainst int
+}
+
+var ErrGroupStageNotFinished = errors.New("tournament: group stage is not finished")
+
+// Standings computes the table of every group: 3 points for a win, 1 for a draw.
+// Ties are broken by score difference, then by wins.
+func Standings(matches []models.Match) map[string][]Standing {
+	rows := make(map[string]map[int64]*Standing)
+	for _, m := range matches {
+		if m.Stage != models.StageGroup {
+			continue
+		}
+		if rows[m.GroupName] == nil {
+			rows[m.GroupName] = make(map[int64]*Standing)
+		}
+		g := rows[m.GroupName]
+		for _, p := range []int64{m.Player1, m.Player2} {
+			if g[p] == nil {
+				g[p] = &Standing{Player: p}
+			}
+		}
+		if m.Status != models.MatchDone {
+			continue
+		}
+
+		s1, s2 := g[m.Player1], g[m.Player2]
+		s1.Played++
+		s2.Played++
+		s1.ScoreFor += m.Score1
+		s1.ScoreAgainst += m.Score2
+		s2.ScoreFor += m.Score2
+		s2.ScoreAgainst += m.Score1
+		switch m.Winner {
+		case m.Player1:
+			s1.Wins++
+			s1.Points += 3
+			s2.Losses++
+		case m.Player2:
+			s2.Wins++
+			s2.Points += 3
+			s1.Losses++
+		default:
+			s1.Draws++
+			s2.Draws++
+			s1.Points++
+			s2.Points++
+		}
+	}
+
+	result := make(map[string][]Standing, len(rows))
+	for name, g := range rows {
+		table := make([]Standing, 0, len(g))
+		for _, s := range g {
+			table = append(table, *s)
+		}
+		sort.Slice(table, func(i, j int) bool {
+			a, b := table[i], table[j]
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+			if da, db := a.ScoreFor-a.ScoreAgainst, b.ScoreFor-b.ScoreAgainst; da != db {
+				return da > db
+			}
+			if a.Wins != b.Wins {
+				return a.Wins > b.Wins
+			}
+			return a.Player < b.Player
+		})
+		result[name] = table
+	}
+	return result
+}
+
+// GroupStageFinished reports whether every group match has a result
+func GroupStageFinished(matches []models.Match) bool {
+	for _, m := range matches {
+		if m.Stage == models.StageGroup && m.Status != models.MatchDone {
+			return false
+		}
+	}
+	return true
+}
+
+// SeedPlayoff resolves the group places referenced by the first playoff round
+// into players and advances byes. It modifies matches in place and returns
+// the indexes of the changed matches.
+func SeedPlayoff(matches []models.Match) ([]int, error) {
+	if !GroupStageFinished(matches) {
+		return nil, ErrGroupStageNotFinished
+	}
+	tables := Standings(matches)
+
+	resolve := func(label string) (int64, error) {
+		if label == "" {
+			return 0, nil
+		}
+		var group string
+		var place int
+		if _, err := fmt.Sscanf(label, "%1s%d", &group, &place); err != nil {
+			return 0, fmt.Errorf("tournament: bad source %q: %w", label, err)
+		}
+		table := tables[group]
+		if place < 1 || place > len(table) {
+			return 0, fmt.Errorf("tournament: group %s has no place %d", group, place)
+		}
+		return table[place-1].Player, nil
+	}
+
+	var changed []int
+	for i := range matches {
+		m := &matches[i]
+		if m.Stage != models.StagePlayoff || m.Round != 1 {
+			continue
+		}
+		p1, err := resolve(m.Source1)
+		if err != nil {
+			return nil, err
+		}
+		p2, err := resolve(m.Source2)
+		if err != nil {
+			return nil, err
+		}
+		m.Player1, m.Player2 = p1, p2
+		changed = append(changed, i)
+
+		if p2 == 0 && p1 != 0 {
+			// соперника нет — игрок проходит дальше автоматически
+			m.Winner = p1
+			m.Status = models.MatchDone
+			if next := advanceWinner(matches, i); next >= 0 {
+				changed = append(changed, next)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// advanceWinner puts the winner of the playoff match at index i into the next
+// round and returns the index of that match, or -1 for the final
+func advanceWinner(matches []models.Match, i int) int {
+	m := matches[i]
+	for j := range matches {
+		n := &matches[j]
+		if n.Discipline != m.Discipline || n.Stage != models.StagePlayoff || n.Round != m.Round+1 || n.Slot != m.Slot/2 {
+			continue
+		}
+		if m.Slot%2 == 0 {
+			n.Player1 = m.Winner
+		} else {
+			n.Player2 = m.Winner
+		}
+		return j
+	}
+	return -1
+}
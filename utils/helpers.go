@@ -1,27 +1,31 @@
 package utils
 
 import (
-	"regexp"
+	"fmt"
+	"tgbot/registry"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-var tagRe = regexp.MustCompile(`^#\w+$`)
-
-func ValidateTag(tag string) bool {
-	return tagRe.MatchString(tag)
-}
-
+// DisciplineKeyboard builds the game choice keyboard from the discipline registry, two buttons per row
 func DisciplineKeyboard() tgbotapi.InlineKeyboardMarkup {
-	btnBS := tgbotapi.NewInlineKeyboardButtonData("Brawl Stars", "disc_bs")
-	btnCR := tgbotapi.NewInlineKeyboardButtonData("Clash Royale", "disc_cr")
-	btnCH := tgbotapi.NewInlineKeyboardButtonData("Chess", "disc_ch")
-	btnTR := tgbotapi.NewInlineKeyboardButtonData("Триатлон (все 3)", "disc_tri")
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(btnBS, btnCR),
-		tgbotapi.NewInlineKeyboardRow(btnCH, btnTR),
-	)
-	return kb
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, d := range registry.All() {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(d.Name, "disc_"+d.Code))
+	}
+	if tri := registry.Triathlon(); len(tri) > 1 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Триатлон (все %d)", len(tri)), "disc_tri"))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(buttons); i += 2 {
+		end := i + 2
+		if end > len(buttons) {
+			end = len(buttons)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttons[i:end]...))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
 func RulesOkButton(code string) tgbotapi.InlineKeyboardMarkup {
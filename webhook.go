@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookPath — путь HTTP-сервера, на который Telegram присылает обновления
+const webhookPath = "/telegram/webhook"
+
+// secretHeader — заголовок, в котором Telegram передаёт secret_token вебхука
+const secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookReceiver принимает обновления от Telegram и передаёт их в канал Updates.
+// Запросы без правильного секрета отклоняются
+type webhookReceiver struct {
+	api    *tgbotapi.BotAPI
+	secret string
+
+	updates chan tgbotapi.Update
+	stop    chan struct{} // закрыт — обновления больше не принимаются
+
+	mu       sync.Mutex
+	stopped  bool
+	inflight sync.WaitGroup // запросы, которые ещё могут писать в updates
+}
+
+func newWebhookReceiver(api *tgbotapi.BotAPI, secret string) *webhookReceiver {
+	return &webhookReceiver{
+		api:     api,
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, api.Buffer),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Updates — канал обновлений; закрывается после Close, когда все запросы завершились
+func (wr *webhookReceiver) Updates() tgbotapi.UpdatesChannel {
+	return wr.updates
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get(secretHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(wr.secret)) != 1 {
+		log.Printf("Webhook request with bad secret from %s", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	update, err := wr.api.HandleUpdate(r)
+	if err != nil {
+		log.Printf("Webhook decode error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	wr.mu.Lock()
+	if wr.stopped {
+		wr.mu.Unlock()
+		// Telegram повторит обновление, и его получит следующий запуск бота
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	wr.inflight.Add(1)
+	wr.mu.Unlock()
+	defer wr.inflight.Done()
+
+	// Если очередь заполнена, Telegram подождёт ответа — это естественное ограничение нагрузки
+	select {
+	case wr.updates <- *update:
+		w.WriteHeader(http.StatusOK)
+	case <-wr.stop:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+// Close перестаёт принимать обновления и закрывает канал, когда начатые запросы
+// вернулись: ждать HTTP-сервер для этого не нужно
+func (wr *webhookReceiver) Close() {
+	wr.mu.Lock()
+	if wr.stopped {
+		wr.mu.Unlock()
+		return
+	}
+	wr.stopped = true
+	close(wr.stop)
+	wr.mu.Unlock()
+
+	wr.inflight.Wait()
+	close(wr.updates)
+}
+
+// setWebhook регистрирует вебхук в Telegram. tgbotapi.WebhookConfig не умеет
+// передавать secret_token, поэтому запрос собирается вручную
+func setWebhook(api *tgbotapi.BotAPI, publicURL, secret string) (string, error) {
+	link, err := url.JoinPath(publicURL, webhookPath)
+	if err != nil {
+		return "", fmt.Errorf("webhook url: %w", err)
+	}
+	params := tgbotapi.Params{"url": link, "secret_token": secret}
+	if _, err := api.MakeRequest("setWebhook", params); err != nil {
+		return "", fmt.Errorf("set webhook: %w", err)
+	}
+	return link, nil
+}
+
+// deleteWebhook снимает вебхук, иначе getUpdates в режиме long polling вернёт ошибку
+func deleteWebhook(api *tgbotapi.BotAPI) {
+	if _, err := api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("delete webhook: %v", err)
+	}
+}